@@ -0,0 +1,270 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SimonLariz/beacon/internal/model"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// broadcastRefreshInterval is how often the results grid updates while hosts run
+const broadcastRefreshInterval = 500 * time.Millisecond
+
+// broadcastTickMsg refreshes the results grid while a broadcast runs
+type broadcastTickMsg struct{}
+
+// broadcastTick schedules the next results grid refresh
+func broadcastTick() tea.Cmd {
+	return tea.Tick(broadcastRefreshInterval, func(time.Time) tea.Msg {
+		return broadcastTickMsg{}
+	})
+}
+
+// executeBroadcast runs a command on every marked connection, a limited number at a time
+// Marked connections that aren't connected or are busy are listed as skipped
+func (m *TUIModel) executeBroadcast(input string) tea.Cmd {
+	cmd, timeout, err := model.ParseCommandTimeout(input)
+	if err != nil {
+		m.mode = ModeNormal
+		m.setStatus(fmt.Sprintf("Error: %v", err), 5*time.Second)
+		return nil
+	}
+
+	broadcast := &model.Broadcast{Command: cmd, Timestamp: time.Now()}
+	sem := make(chan struct{}, m.AppState.Config.BroadcastConcurrencyFor())
+
+	type pending struct {
+		index     int
+		state     *model.ConnectionState
+		execution *model.CommandExecution
+	}
+	var runs []pending
+	for i, cs := range m.AppState.Connections {
+		if !cs.Marked {
+			continue
+		}
+		host := &model.BroadcastHost{State: cs}
+		broadcast.Hosts = append(broadcast.Hosts, host)
+		switch {
+		case cs.Status != model.StatusConnected || cs.Client == nil:
+			host.Skipped = "not connected"
+			continue
+		case cs.CurrentExec != nil:
+			host.Skipped = "busy with another command"
+			continue
+		}
+
+		execution := model.NewCommandExecution(cmd)
+		execution.Timeout = timeout
+		if execution.Timeout == 0 {
+			execution.Timeout = m.AppState.Config.CommandTimeoutFor(cs.Connection)
+		}
+		host.Execution = execution
+		runs = append(runs, pending{index: i, state: cs, execution: execution})
+	}
+
+	m.broadcast = broadcast
+	m.broadcastIndex = 0
+	m.mode = ModeBroadcast
+	if len(runs) == 0 {
+		m.setStatus("None of the marked connections can run the command", 3*time.Second)
+		return nil
+	}
+
+	// Every host is registered before any of them starts
+	cmds := []tea.Cmd{broadcastTick()}
+	for _, run := range runs {
+		cmds = append(cmds, m.startExecution(run.index, run.state, run.execution, sem, broadcast))
+	}
+	return tea.Batch(cmds...)
+}
+
+// broadcastProgress reports once every host of a broadcast has finished
+func (m *TUIModel) broadcastProgress(broadcast *model.Broadcast) {
+	if broadcast.Finished() < len(broadcast.Hosts) {
+		return
+	}
+	counts := make(map[string]int)
+	for _, host := range broadcast.Hosts {
+		switch {
+		case host.Status() == model.BroadcastDone && host.Execution.Outcome == model.OutcomeExited &&
+			host.Execution.ExitCode == 0:
+			counts["ok"]++
+		case host.Status() == model.BroadcastSkipped:
+			counts["skipped"]++
+		default:
+			counts["failed"]++
+		}
+	}
+	m.setStatus(fmt.Sprintf("Broadcast finished: %d ok, %d failed, %d skipped",
+		counts["ok"], counts["failed"], counts["skipped"]), 5*time.Second)
+}
+
+// cancelBroadcast interrupts every host of the broadcast that is still queued or running
+func (m *TUIModel) cancelBroadcast(broadcast *model.Broadcast) {
+	for _, host := range broadcast.Hosts {
+		if host.Execution == nil {
+			continue
+		}
+		if cancel, ok := m.cancels[host.Execution]; ok {
+			cancel()
+		}
+	}
+	m.setStatus("Interrupting broadcast...", 5*time.Second)
+}
+
+// handleBroadcastKey processes key input in the broadcast results grid
+func (m *TUIModel) handleBroadcastKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	broadcast := m.broadcast
+	if broadcast == nil {
+		m.mode = ModeNormal
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "esc", "q", "B":
+		m.mode = ModeNormal
+	case "ctrl+c":
+		if broadcast.Finished() < len(broadcast.Hosts) {
+			m.cancelBroadcast(broadcast)
+			return m, nil
+		}
+		return m, tea.Quit
+	case "up":
+		if m.broadcastIndex > 0 {
+			m.broadcastIndex--
+		}
+	case "down":
+		if m.broadcastIndex < len(broadcast.Hosts)-1 {
+			m.broadcastIndex++
+		}
+	case "d":
+		m.compareBroadcast()
+	case "enter":
+		// Show the host's full output in the main view
+		host := broadcast.Hosts[m.broadcastIndex]
+		for i, cs := range m.AppState.Connections {
+			if cs == host.State {
+				m.AppState.SelectConnection(i)
+				m.AppState.OutputScrollOffset = 0
+				m.mode = ModeNormal
+				break
+			}
+		}
+	}
+	return m, nil
+}
+
+// handleMarkInput processes key input while typing a pattern to mark connections by
+func (m *TUIModel) handleMarkInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.mode = ModeNormal
+		m.markInput = ""
+	case "enter":
+		matched := m.AppState.MarkMatching(m.markInput)
+		m.setStatus(fmt.Sprintf("Marked %d connection(s) matching %q", matched, m.markInput), 3*time.Second)
+		m.mode = ModeNormal
+		m.markInput = ""
+	case "backspace":
+		if len(m.markInput) > 0 {
+			m.markInput = m.markInput[:len(m.markInput)-1]
+		}
+	default:
+		if len(msg.String()) == 1 {
+			m.markInput += msg.String()
+		}
+	}
+	return m, nil
+}
+
+// renderMarkInput renders the pattern input bar
+func (m *TUIModel) renderMarkInput() string {
+	var result string
+	result += "\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n"
+	result += fmt.Sprintf("Mark matching: %s█\n", m.markInput)
+	result += "Glob on alias or host, e.g. web-* or *.prod.example.com, or group:prod/db, tag:web [Enter] mark [Esc] cancel\n"
+	return result
+}
+
+// renderBroadcast renders the per-host results grid of the last broadcast
+func (m *TUIModel) renderBroadcast() string {
+	broadcast := m.broadcast
+	if broadcast == nil {
+		return ""
+	}
+
+	var result string
+	result += fmt.Sprintf("=== BROADCAST: %s  [%s] ===\n\n", broadcast.Command, broadcast.Timestamp.Format("15:04:05"))
+	result += fmt.Sprintf("  %-20s %-10s %6s %10s  %s\n", "HOST", "STATUS", "EXIT", "DURATION", "OUTPUT")
+
+	for i, host := range broadcast.Hosts {
+		marker := "  "
+		if i == m.broadcastIndex {
+			marker = "> "
+		}
+
+		status := host.Status()
+		statusText := status.String()
+		exitCode, duration, output := "", "", ""
+		switch status {
+		case model.BroadcastSkipped:
+			output = host.Skipped
+		case model.BroadcastFailed:
+			output = host.Err.Error()
+		case model.BroadcastQueued:
+			// The clock only starts once the host gets a slot
+			output = "waiting for a free slot"
+		case model.BroadcastRunning:
+			duration = time.Since(host.StartedAt()).Round(time.Second).String()
+			output = lastOutputLine(host.Execution)
+		case model.BroadcastDone:
+			exec := host.Execution
+			switch exec.Outcome {
+			case model.OutcomeTimedOut:
+				statusText = "timed out"
+			case model.OutcomeCancelled:
+				statusText = "cancelled"
+			default:
+				exitCode = fmt.Sprintf("%d", exec.ExitCode)
+			}
+			duration = exec.Duration.Round(time.Millisecond).String()
+			output = lastOutputLine(exec)
+		}
+
+		line := fmt.Sprintf("%s%-20s %-10s %6s %10s  %s", marker, truncate(host.State.Connection.Alias, 20),
+			statusText, exitCode, duration, output)
+		if status == model.BroadcastFailed || (exitCode != "" && exitCode != "0") {
+			line = stderrStyle.Render(line)
+		}
+		result += line + "\n"
+	}
+
+	result += fmt.Sprintf("\n%d/%d finished\n", broadcast.Finished(), len(broadcast.Hosts))
+	result += "\n[Enter] show output [d]iff outputs [Ctrl+C] interrupt [Esc] back\n"
+
+	if time.Now().Before(m.statusTimeout) {
+		result += fmt.Sprintf("\n%s\n", m.statusMessage)
+	}
+	return result
+}
+
+// lastOutputLine returns the last non-empty output line of an execution for the grid
+func lastOutputLine(exec *model.CommandExecution) string {
+	if exec.Output == nil {
+		return ""
+	}
+	lines := exec.Output.Lines()
+	for i := len(lines) - 1; i >= 0; i-- {
+		var line string
+		for _, fragment := range lines[i] {
+			line += fragment.Text
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			return truncate(line, 50)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/SimonLariz/beacon/internal/model"
+)
+
+// printUsage prints the subcommand overview
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage:")
+	fmt.Fprintln(w, "  beacon                      Start the interactive session manager")
+	fmt.Fprintln(w, "  beacon list [TARGET]        List saved connections, or those TARGET names")
+	fmt.Fprintln(w, "  beacon add --alias NAME --host HOST [--user USER] [--port PORT] [--key PATH] [--jump HOPS]")
+	fmt.Fprintln(w, "             [--group GROUP] [--tags TAGS]")
+	fmt.Fprintln(w, "                              Save a new connection")
+	fmt.Fprintln(w, "  beacon rm ALIAS             Remove a saved connection")
+	fmt.Fprintln(w, "  beacon exec [--timeout DURATION] [--output text|table|json|ndjson] TARGET -- COMMAND...")
+	fmt.Fprintln(w, "                              Run a command on the connections TARGET names, exiting")
+	fmt.Fprintln(w, "                              with the remote exit code")
+	fmt.Fprintln(w, "  beacon import ssh-config    Import Host entries from ~/.ssh/config")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "TARGET is an alias, a glob on alias or host (web-*), group:GROUP to include its")
+	fmt.Fprintln(w, "subgroups (group:prod selects prod/db), or tag:TAG")
+}
+
+// runCLI runs a non-interactive subcommand and returns the process exit code
+func runCLI(args []string) int {
+	switch args[0] {
+	case "list", "ls":
+		return runList(args[1:])
+	case "add":
+		return runAdd(args[1:])
+	case "rm", "remove":
+		return runRemove(args[1:])
+	case "exec":
+		return runExec(args[1:])
+	case "import":
+		return runImport(args[1:])
+	case "help", "-h", "--help":
+		printUsage(os.Stdout)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "beacon: unknown command %q\n\n", args[0])
+		printUsage(os.Stderr)
+		return 2
+	}
+}
+
+// runImport implements `beacon import ssh-config`
+func runImport(args []string) int {
+	if len(args) != 1 || args[0] != "ssh-config" {
+		fmt.Fprintln(os.Stderr, "usage: beacon import ssh-config")
+		return 2
+	}
+
+	config, err := model.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "beacon: %v\n", err)
+		return 1
+	}
+
+	imported, err := model.ConnectionsFromSSHConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "beacon: %v\n", err)
+		return 1
+	}
+
+	result := config.MergeConnections(imported)
+	if len(result.Added)+len(result.Merged) > 0 {
+		if err := model.SaveConfig(config); err != nil {
+			fmt.Fprintf(os.Stderr, "beacon: %v\n", err)
+			return 1
+		}
+	}
+
+	for _, conn := range result.Added {
+		fmt.Printf("added   %s (%s)\n", conn.Alias, conn.Address())
+	}
+	for _, conn := range result.Merged {
+		fmt.Printf("merged  %s (%s)\n", conn.Alias, conn.Address())
+	}
+	for _, alias := range result.Skipped {
+		fmt.Printf("skipped %s\n", alias)
+	}
+	fmt.Printf("Imported from ~/.ssh/config: %s\n", result)
+	return 0
+}
+
+// runList implements `beacon list`
+func runList(args []string) int {
+	if len(args) > 1 {
+		fmt.Fprintln(os.Stderr, "usage: beacon list [TARGET]")
+		return 2
+	}
+
+	config, err := model.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "beacon: %v\n", err)
+		return 1
+	}
+	conns := config.Connections
+	if len(args) == 1 {
+		if conns = config.SelectConnections(args[0]); len(conns) == 0 {
+			fmt.Fprintf(os.Stderr, "beacon: no connection matches %q\n", args[0])
+			return 1
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ALIAS\tADDRESS\tUSER\tVIA\tGROUP\tTAGS")
+	for _, conn := range conns {
+		user := conn.User
+		if user == "" {
+			user = "-"
+		}
+		via := config.HopPath(conn)
+		if via == "" {
+			via = "-"
+		}
+		group := conn.Group
+		if group == "" {
+			group = "-"
+		}
+		tags := strings.Join(conn.Tags, ",")
+		if tags == "" {
+			tags = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", conn.Alias, conn.Address(), user, via, group, tags)
+	}
+	w.Flush()
+	return 0
+}
+
+// runAdd implements `beacon add`
+func runAdd(args []string) int {
+	fs := flag.NewFlagSet("add", flag.ContinueOnError)
+	alias := fs.String("alias", "", "nickname of the connection (required)")
+	host := fs.String("host", "", "IP, hostname or ssh config alias (required)")
+	user := fs.String("user", "", "SSH user, blank to use ssh config")
+	port := fs.Int("port", 0, "SSH port, 0 to use ssh config or 22")
+	keyPath := fs.String("key", "", "path to a private key")
+	jumps := fs.String("jump", "", "comma separated jump hosts, aliases or user@host:port")
+	group := fs.String("group", "", "slash separated group, e.g. prod/db")
+	tags := fs.String("tags", "", "comma separated tags, e.g. web,env=prod")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *alias == "" || *host == "" || fs.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "usage: beacon add --alias NAME --host HOST [--user USER] [--port PORT] [--key PATH] [--jump HOPS] [--group GROUP] [--tags TAGS]")
+		return 2
+	}
+	if *port < 0 || *port > 65535 {
+		fmt.Fprintf(os.Stderr, "beacon: invalid port %d\n", *port)
+		return 2
+	}
+
+	config, err := model.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "beacon: %v\n", err)
+		return 1
+	}
+	for _, err := range []error{
+		config.ValidateAlias(*alias, nil),
+		model.ValidateHost(*host),
+		model.ValidateUser(*user),
+		model.ValidateKeyPath(*keyPath),
+	} {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "beacon: %v\n", err)
+			return 1
+		}
+	}
+
+	conn := model.NewConnection(*alias, *host, *user, *port)
+	conn.KeyPath = *keyPath
+	for _, hop := range strings.Split(*jumps, ",") {
+		if hop = strings.TrimSpace(hop); hop != "" {
+			conn.Jumps = append(conn.Jumps, hop)
+		}
+	}
+	conn.Group = model.NormalizeGroup(*group)
+	conn.Tags = model.ParseTags(*tags)
+	if _, err := config.ResolveJumps(conn); err != nil {
+		fmt.Fprintf(os.Stderr, "beacon: %v\n", err)
+		return 1
+	}
+
+	config.Connections = append(config.Connections, conn)
+	if err := model.SaveConfig(config); err != nil {
+		fmt.Fprintf(os.Stderr, "beacon: %v\n", err)
+		return 1
+	}
+	fmt.Printf("added %s (%s)\n", conn.Alias, conn.Address())
+	return 0
+}
+
+// runRemove implements `beacon rm`
+func runRemove(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: beacon rm ALIAS")
+		return 2
+	}
+
+	config, err := model.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "beacon: %v\n", err)
+		return 1
+	}
+	if err := config.RemoveConnection(args[0]); err != nil {
+		fmt.Fprintf(os.Stderr, "beacon: %v\n", err)
+		return 1
+	}
+	if err := model.SaveConfig(config); err != nil {
+		fmt.Fprintf(os.Stderr, "beacon: %v\n", err)
+		return 1
+	}
+	if err := model.DeleteHistory(args[0]); err != nil {
+		fmt.Fprintf(os.Stderr, "beacon: %v\n", err)
+	}
+	fmt.Printf("removed %s\n", args[0])
+	return 0
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/SimonLariz/beacon/internal/model"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// openCompare compares the latest output of command across connections
+// The view returns to the mode it was opened from
+func (m *TUIModel) openCompare(command string, states []*model.ConnectionState) {
+	comparison := model.CompareOutputs(states, command)
+	if len(comparison.Groups) == 0 {
+		m.setStatus(fmt.Sprintf("No completed runs of %q to compare", command), 3*time.Second)
+		return
+	}
+	m.comparison = comparison
+	m.compareGroup = 0
+	if len(comparison.Groups) > 1 {
+		// Start on the first odd one out
+		m.compareGroup = 1
+	}
+	m.compareScroll = 0
+	m.compareReturn = m.mode
+	m.mode = ModeCompare
+	m.refreshCompare()
+}
+
+// refreshCompare renders the lines below the group list for the selected
+// group, diffing is too slow to repeat on every frame
+func (m *TUIModel) refreshCompare() {
+	comparison := m.comparison
+	majority := comparison.Groups[0]
+	group := comparison.Groups[m.compareGroup]
+
+	switch {
+	case len(comparison.Groups) == 1:
+		m.compareLines = append([]string{fmt.Sprintf("All %d host(s) produced identical output", len(group.States)), ""},
+			model.SplitLines(group.Output)...)
+	case m.compareGroup == 0:
+		m.compareLines = append([]string{"Output of the majority, select another group to diff it", ""},
+			model.SplitLines(group.Output)...)
+	case m.compareSideBySide:
+		m.compareLines = m.sideBySideLines(majority, group)
+	default:
+		lines, err := model.UnifiedDiff("[A] majority", fmt.Sprintf("[%c]", 'A'+rune(m.compareGroup%26)),
+			model.SplitLines(majority.Output), model.SplitLines(group.Output), 3)
+		if err != nil {
+			m.compareLines = []string{fmt.Sprintf("Outputs differ, %v", err)}
+			return
+		}
+		for i, line := range lines {
+			lines[i] = renderDiffLine(line)
+		}
+		m.compareLines = lines
+	}
+}
+
+// compareHeight is the number of diff lines the compare view shows at once
+func (m *TUIModel) compareHeight() int {
+	return max(m.height-len(m.comparison.Groups)-12, 5)
+}
+
+// compareSelected compares the selected connection's last command across every connection
+func (m *TUIModel) compareSelected() {
+	selected := m.AppState.GetSelected()
+	if selected == nil || len(selected.Executions) == 0 {
+		m.setStatus("Run a command first to compare its output across hosts", 3*time.Second)
+		return
+	}
+	command := selected.Executions[len(selected.Executions)-1].Command
+	m.openCompare(command, m.AppState.Connections)
+}
+
+// compareBroadcast compares the output of the broadcast's hosts
+func (m *TUIModel) compareBroadcast() {
+	broadcast := m.broadcast
+	var states []*model.ConnectionState
+	for _, host := range broadcast.Hosts {
+		states = append(states, host.State)
+	}
+	m.openCompare(broadcast.Command, states)
+}
+
+// handleCompareKey processes key input in the output comparison view
+func (m *TUIModel) handleCompareKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	comparison := m.comparison
+	switch msg.String() {
+	case "esc", "q":
+		m.mode = m.compareReturn
+	case "ctrl+c":
+		return m, tea.Quit
+	case "tab":
+		m.compareSideBySide = !m.compareSideBySide
+		m.compareScroll = 0
+		m.refreshCompare()
+	case "up":
+		if m.compareGroup > 0 {
+			m.compareGroup--
+			m.compareScroll = 0
+			m.refreshCompare()
+		}
+	case "down":
+		if m.compareGroup < len(comparison.Groups)-1 {
+			m.compareGroup++
+			m.compareScroll = 0
+			m.refreshCompare()
+		}
+	case "pgup":
+		m.compareScroll = max(m.compareScroll-10, 0)
+	case "pgdown":
+		m.compareScroll = min(m.compareScroll+10, max(len(m.compareLines)-m.compareHeight(), 0))
+	}
+	return m, nil
+}
+
+// renderCompare renders the groups of identical output and the diff of the
+// selected group against the majority
+func (m *TUIModel) renderCompare() string {
+	comparison := m.comparison
+
+	var result string
+	result += fmt.Sprintf("=== COMPARE OUTPUT: %s ===\n\n", comparison.Command)
+
+	for i, group := range comparison.Groups {
+		marker := "  "
+		if i == m.compareGroup {
+			marker = "> "
+		}
+		note := ""
+		if i == 0 && len(comparison.Groups) > 1 {
+			note = " (majority)"
+		}
+		result += fmt.Sprintf("%s[%c] %d host(s)%s: %s\n", marker, 'A'+rune(i%26), len(group.States), note,
+			groupHosts(group))
+	}
+	if len(comparison.Missing) > 0 {
+		var aliases []string
+		for _, cs := range comparison.Missing {
+			aliases = append(aliases, cs.Connection.Alias)
+		}
+		result += fmt.Sprintf("   Not run: %s\n", strings.Join(aliases, ", "))
+	}
+	result += "\n"
+
+	lines := m.compareLines
+	height := m.compareHeight()
+	start := min(m.compareScroll, max(len(lines)-height, 0))
+	end := min(start+height, len(lines))
+	for _, line := range lines[start:end] {
+		result += line + "\n"
+	}
+	if len(lines) > height {
+		result += fmt.Sprintf("\n[Lines %d-%d of %d] [PgUp/PgDown to scroll]\n", start+1, end, len(lines))
+	}
+
+	result += "\n[↑↓] group [Tab] unified/side-by-side [Esc] back\n"
+	return result
+}
+
+// sideBySideLines renders the majority output and a group's output in two columns
+func (m *TUIModel) sideBySideLines(majority, group *model.OutputGroup) []string {
+	width := m.width
+	if width < 40 {
+		width = 80
+	}
+	column := (width - 3) / 2
+
+	diff, err := model.DiffLines(model.SplitLines(majority.Output), model.SplitLines(group.Output))
+	if err != nil {
+		return []string{fmt.Sprintf("Outputs differ, %v", err)}
+	}
+	rows := model.SideBySide(diff)
+	lines := []string{fmt.Sprintf("%s   %s", padRight("[A] majority", column),
+		fmt.Sprintf("[%c] %s", 'A'+rune(m.compareGroup%26), groupHosts(group)))}
+	for _, row := range rows {
+		// Markers follow sdiff: | changed, < only on the left, > only on the right
+		left, right := padRight(row.Left, column), truncate(expandTabs(row.Right), column)
+		var line string
+		switch {
+		case row.Op == model.DiffEqual:
+			line = left + "   " + right
+		case row.HasLeft && row.HasRight:
+			line = diffHunkStyle.Render(left + " | " + right)
+		case row.HasLeft:
+			line = diffDelStyle.Render(left + " <")
+		default:
+			line = diffAddStyle.Render(left + " > " + right)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// groupHosts lists the hosts of a group with their exit codes when they differ from 0
+func groupHosts(group *model.OutputGroup) string {
+	var hosts []string
+	for i, cs := range group.States {
+		host := cs.Connection.Alias
+		if exec := group.Executions[i]; exec.Outcome != model.OutcomeExited {
+			host += fmt.Sprintf(" (%s)", exec.Outcome)
+		} else if exec.ExitCode != 0 {
+			host += fmt.Sprintf(" (exit %d)", exec.ExitCode)
+		}
+		hosts = append(hosts, host)
+	}
+	return strings.Join(hosts, ", ")
+}
+
+// padRight truncates or pads s with spaces to exactly width runes
+func padRight(s string, width int) string {
+	s = truncate(expandTabs(s), width)
+	return s + strings.Repeat(" ", width-utf8.RuneCountInString(s))
+}
+
+// expandTabs replaces tabs so columns line up
+func expandTabs(s string) string {
+	return strings.ReplaceAll(s, "\t", "    ")
+}
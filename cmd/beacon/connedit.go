@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/SimonLariz/beacon/internal/model"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// openEditForm opens the form on the selected connection
+func (m *TUIModel) openEditForm() {
+	selected := m.AppState.GetSelected()
+	if selected == nil {
+		m.setStatus("Select a connection to edit", 2*time.Second)
+		return
+	}
+	m.mode = ModeAddForm
+	m.form = NewEditConnectionForm(selected.Connection)
+}
+
+// submitForm adds the connection the form describes, or applies the edit
+// Errors are shown in the form, which stays open
+func (m *TUIModel) submitForm() {
+	conn, err := m.form.connection(m.AppState.Config)
+	if err != nil {
+		// Shown next to the field it is about
+		return
+	}
+
+	if m.form.editing == nil {
+		m.AppState.AddConnection(conn)
+	} else if err := m.applyEdit(m.form.editing, conn); err != nil {
+		m.form.err = err.Error()
+		return
+	}
+	if err := model.SaveConfig(m.AppState.Config); err != nil {
+		log.Printf("Warning: failed to save config: %v", err)
+	}
+
+	m.mode = ModeNormal
+	m.form = NewAddConnectionForm()
+}
+
+// applyEdit copies the edited fields onto a saved connection, which the live
+// connection state shares, and offers to reconnect when the target changed
+func (m *TUIModel) applyEdit(conn, edited *model.Connection) error {
+	oldAlias := conn.Alias
+	if err := m.AppState.Config.RenameConnection(conn, edited.Alias); err != nil {
+		return err
+	}
+	if oldAlias != conn.Alias {
+		if err := model.RenameHistory(oldAlias, conn.Alias); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+
+	retarget := conn.Host != edited.Host || conn.Port != edited.Port || conn.User != edited.User ||
+		conn.KeyPath != edited.KeyPath || !slices.Equal(conn.Jumps, edited.Jumps)
+	if conn.Host != edited.Host || conn.User != edited.User {
+		// A remembered password belongs to the old account
+		conn.Password = ""
+	}
+	conn.Host = edited.Host
+	conn.Port = edited.Port
+	conn.User = edited.User
+	conn.KeyPath = edited.KeyPath
+	conn.Jumps = edited.Jumps
+	conn.Group = edited.Group
+	conn.Tags = edited.Tags
+
+	m.setStatus(fmt.Sprintf("Updated %s", conn.Alias), 3*time.Second)
+	for _, cs := range m.AppState.Connections {
+		if cs.Connection == conn && retarget && cs.Status == model.StatusConnected {
+			m.reconnectPrompt = cs
+		}
+	}
+	return nil
+}
+
+// handleReconnectPrompt answers the offer to reconnect an edited connection
+func (m *TUIModel) handleReconnectPrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	cs := m.reconnectPrompt
+	switch msg.String() {
+	case "y", "Y":
+		m.reconnectPrompt = nil
+		return m, m.reconnect(cs)
+	case "n", "N", "esc":
+		m.reconnectPrompt = nil
+		m.setStatus(fmt.Sprintf("%s keeps its current session until it reconnects", cs.Connection.Alias), 3*time.Second)
+	case "ctrl+c":
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// renderReconnectPrompt renders the offer to reconnect an edited connection
+func (m *TUIModel) renderReconnectPrompt() string {
+	cs := m.reconnectPrompt
+	var result string
+	result += "=== CONNECTION CHANGED ===\n\n"
+	result += fmt.Sprintf("%s is connected with its old settings.\n", cs.Connection.Alias)
+	if cs.CurrentExec != nil {
+		result += fmt.Sprintf("Reconnecting interrupts %q.\n", cs.CurrentExec.Command)
+	}
+	result += fmt.Sprintf("\nReconnect to %s now? [y/n]\n", cs.Connection.Address())
+	return result
+}
+
+// reconnect drops a connection's session and connects again with its current settings
+func (m *TUIModel) reconnect(cs *model.ConnectionState) tea.Cmd {
+	for _, state := range m.AppState.Connections {
+		if state != cs {
+			continue
+		}
+		m.disconnect(cs)
+		cs.Status = model.StatusConnecting
+		cs.LastError = nil
+		return m.connectToServer(cs)
+	}
+	return nil
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/SimonLariz/beacon/internal/model"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Diff line highlighting
+var (
+	diffAddStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	diffDelStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	diffHunkStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("6"))
+)
+
+// elevatedTimeout bounds the commands used to read and write files as root
+const elevatedTimeout = 30 * time.Second
+
+// remoteEdit is a remote file being edited in the local $EDITOR
+type remoteEdit struct {
+	path      string      // Remote path
+	elevate   string      // Elevation command, empty to go through sftp
+	localPath string      // Temp copy handed to the editor
+	mode      os.FileMode // Remote mode bits, restored on save over sftp
+	modTime   time.Time   // Remote mtime the edit started from, zero when elevated
+	size      int64       // Remote size the edit started from
+	base      [32]byte    // Hash of the remote content the edit started from
+	edited    []byte      // Local contents once the editor exited
+	current   []byte      // Remote contents when they changed under the edit
+}
+
+// name returns the file name for status messages
+func (e *remoteEdit) name() string {
+	return path.Base(e.path)
+}
+
+// cleanup removes the temp copy
+func (e *remoteEdit) cleanup() {
+	if e.localPath != "" {
+		os.RemoveAll(filepath.Dir(e.localPath))
+	}
+}
+
+// editFetchedMsg is sent once the remote file has been read for editing
+type editFetchedMsg struct {
+	edit *remoteEdit
+	data []byte
+	err  error
+}
+
+// editorExitMsg is sent when the editor returns control to the TUI
+type editorExitMsg struct {
+	edit *remoteEdit
+	err  error
+}
+
+// editCheckedMsg carries the remote file as it is now, read just before saving
+type editCheckedMsg struct {
+	edit    *remoteEdit
+	changed bool
+	current []byte
+	err     error
+}
+
+// editSavedMsg is sent once the edited file has been written back
+type editSavedMsg struct {
+	edit *remoteEdit
+	err  error
+}
+
+// editorCommand returns the user's editor split into arguments
+func editorCommand() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if args := strings.Fields(os.Getenv(env)); len(args) > 0 {
+			return args
+		}
+	}
+	return []string{"vi"}
+}
+
+// startEdit fetches the selected remote file for editing
+// elevated reads and writes the file through the connection's elevation command
+func (m *TUIModel) startEdit(elevated bool) tea.Cmd {
+	browser := m.files
+	if browser.active != 1 {
+		m.setStatus("Select a file in the remote pane to edit it", 3*time.Second)
+		return nil
+	}
+	pane := browser.panes[1]
+	entry := pane.selected()
+	if entry == nil || entry.IsDir {
+		m.setStatus("Select a file to edit", 3*time.Second)
+		return nil
+	}
+
+	edit := &remoteEdit{path: pane.path(entry.Name)}
+	if elevated {
+		edit.elevate = m.AppState.Config.ElevateCommandFor(browser.state.Connection)
+	}
+	client := browser.client
+	sshClient := browser.state.Client
+
+	m.setStatus(fmt.Sprintf("Fetching %s...", entry.Name), 10*time.Second)
+	return func() tea.Msg {
+		if edit.elevate != "" {
+			ctx, cancel := context.WithTimeout(context.Background(), elevatedTimeout)
+			defer cancel()
+			data, err := sshClient.ReadFileElevated(ctx, edit.elevate, edit.path)
+			return editFetchedMsg{edit: edit, data: data, err: err}
+		}
+
+		data, info, err := client.ReadFile(edit.path)
+		if err != nil {
+			return editFetchedMsg{edit: edit, err: err}
+		}
+		edit.mode = info.Mode
+		edit.modTime = info.ModTime
+		edit.size = info.Size
+		return editFetchedMsg{edit: edit, data: data}
+	}
+}
+
+// editFetched writes the fetched file to a temp copy and opens the editor on it
+func (m *TUIModel) editFetched(msg editFetchedMsg) tea.Cmd {
+	edit := msg.edit
+	if msg.err != nil {
+		if errors.Is(msg.err, os.ErrPermission) && edit.elevate == "" {
+			m.setStatus(fmt.Sprintf("Permission denied reading %s, press E to edit it with %s",
+				edit.name(), m.elevateCommand()), 5*time.Second)
+		} else {
+			m.setStatus(fmt.Sprintf("Error: %v", msg.err), 5*time.Second)
+		}
+		return nil
+	}
+
+	// The file keeps its name so editors pick the right syntax
+	dir, err := os.MkdirTemp("", "beacon-edit-")
+	if err != nil {
+		m.setStatus(fmt.Sprintf("Error: %v", err), 5*time.Second)
+		return nil
+	}
+	edit.localPath = filepath.Join(dir, edit.name())
+	if err := os.WriteFile(edit.localPath, msg.data, 0600); err != nil {
+		edit.cleanup()
+		m.setStatus(fmt.Sprintf("Error: %v", err), 5*time.Second)
+		return nil
+	}
+	edit.base = sha256.Sum256(msg.data)
+	m.statusTimeout = time.Time{}
+	return openEditor(edit)
+}
+
+// openEditor suspends the TUI while the user edits the temp copy
+func openEditor(edit *remoteEdit) tea.Cmd {
+	args := editorCommand()
+	cmd := exec.Command(args[0], append(args[1:], edit.localPath)...)
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return editorExitMsg{edit: edit, err: err}
+	})
+}
+
+// elevateCommand returns the elevation command of the browsed connection
+func (m *TUIModel) elevateCommand() string {
+	return m.AppState.Config.ElevateCommandFor(m.files.state.Connection)
+}
+
+// editorExited checks the remote file for changes before saving the edit
+func (m *TUIModel) editorExited(msg editorExitMsg) tea.Cmd {
+	edit := msg.edit
+	if msg.err != nil {
+		m.setStatus(fmt.Sprintf("Editor failed: %v, your copy is in %s", msg.err, edit.localPath), 10*time.Second)
+		return nil
+	}
+
+	edited, err := os.ReadFile(edit.localPath)
+	if err != nil {
+		m.setStatus(fmt.Sprintf("Error: %v", err), 5*time.Second)
+		return nil
+	}
+	if sha256.Sum256(edited) == edit.base {
+		edit.cleanup()
+		m.setStatus(fmt.Sprintf("No changes to %s", edit.name()), 3*time.Second)
+		return nil
+	}
+	edit.edited = edited
+
+	if m.files == nil {
+		m.setStatus(fmt.Sprintf("File browser closed, your copy is in %s", edit.localPath), 10*time.Second)
+		return nil
+	}
+	client := m.files.client
+	sshClient := m.files.state.Client
+
+	m.setStatus(fmt.Sprintf("Saving %s...", edit.name()), 10*time.Second)
+	return func() tea.Msg {
+		if edit.elevate == "" {
+			// An unchanged mtime and size spare reading the whole file again
+			info, err := client.Stat(edit.path)
+			if err != nil {
+				return editCheckedMsg{edit: edit, err: err}
+			}
+			if info.ModTime.Equal(edit.modTime) && info.Size == edit.size {
+				return editCheckedMsg{edit: edit}
+			}
+			current, _, err := client.ReadFile(edit.path)
+			if err != nil {
+				return editCheckedMsg{edit: edit, err: err}
+			}
+			return editCheckedMsg{edit: edit, changed: sha256.Sum256(current) != edit.base, current: current}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), elevatedTimeout)
+		defer cancel()
+		current, err := sshClient.ReadFileElevated(ctx, edit.elevate, edit.path)
+		if err != nil {
+			return editCheckedMsg{edit: edit, err: err}
+		}
+		return editCheckedMsg{edit: edit, changed: sha256.Sum256(current) != edit.base, current: current}
+	}
+}
+
+// editChecked saves the edit, or asks first when the remote file changed meanwhile
+func (m *TUIModel) editChecked(msg editCheckedMsg) tea.Cmd {
+	edit := msg.edit
+	if msg.err != nil {
+		m.setStatus(fmt.Sprintf("Failed to check %s: %v, your copy is in %s", edit.name(), msg.err, edit.localPath), 10*time.Second)
+		return nil
+	}
+	if m.files == nil {
+		m.setStatus(fmt.Sprintf("File browser closed, your copy is in %s", edit.localPath), 10*time.Second)
+		return nil
+	}
+	if msg.changed {
+		edit.current = msg.current
+		m.files.conflict = edit
+		m.files.diffScroll = 0
+		lines, err := model.UnifiedDiff(edit.path+" (server)", edit.name()+" (your edit)",
+			model.SplitLines(string(edit.current)), model.SplitLines(string(edit.edited)), 3)
+		if err != nil {
+			lines = []string{fmt.Sprintf("The versions differ, %v", err)}
+		}
+		for i, line := range lines {
+			lines[i] = renderDiffLine(line)
+		}
+		m.files.conflictDiff = lines
+		m.statusTimeout = time.Time{}
+		return nil
+	}
+	return m.saveEdit(edit)
+}
+
+// saveEdit writes the edited copy back to the server
+func (m *TUIModel) saveEdit(edit *remoteEdit) tea.Cmd {
+	client := m.files.client
+	sshClient := m.files.state.Client
+	m.setStatus(fmt.Sprintf("Saving %s...", edit.name()), 10*time.Second)
+	return func() tea.Msg {
+		if edit.elevate != "" {
+			ctx, cancel := context.WithTimeout(context.Background(), elevatedTimeout)
+			defer cancel()
+			return editSavedMsg{edit: edit, err: sshClient.WriteFileElevated(ctx, edit.elevate, edit.path, edit.edited)}
+		}
+		return editSavedMsg{edit: edit, err: client.WriteFile(edit.path, edit.edited, edit.mode.Perm())}
+	}
+}
+
+// editSaved reports the result of writing the edit back
+func (m *TUIModel) editSaved(msg editSavedMsg) tea.Cmd {
+	edit := msg.edit
+	if msg.err != nil {
+		m.setStatus(fmt.Sprintf("Failed to save %s: %v, your copy is in %s", edit.name(), msg.err, edit.localPath), 10*time.Second)
+		return nil
+	}
+	edit.cleanup()
+	m.setStatus(fmt.Sprintf("Saved %s", edit.name()), 3*time.Second)
+	if m.files == nil {
+		return nil
+	}
+	remote := m.files.panes[1]
+	return remote.load(remote.dir)
+}
+
+// handleConflictKey processes key input while the remote file changed under an edit
+func (m *TUIModel) handleConflictKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	browser := m.files
+	edit := browser.conflict
+
+	switch msg.String() {
+	case "o":
+		browser.conflict = nil
+		return m, m.saveEdit(edit)
+	case "e":
+		// The edit now starts from the remote changes the user has seen
+		browser.conflict = nil
+		edit.base = sha256.Sum256(edit.current)
+		edit.modTime = time.Time{}
+		edit.current = nil
+		return m, openEditor(edit)
+	case "esc":
+		browser.conflict = nil
+		m.setStatus(fmt.Sprintf("Not saved, your copy is in %s", edit.localPath), 10*time.Second)
+	case "ctrl+c":
+		return m, tea.Quit
+	case "up":
+		if browser.diffScroll > 0 {
+			browser.diffScroll--
+		}
+	case "down":
+		browser.diffScroll = min(browser.diffScroll+1, m.conflictScrollMax())
+	case "pgup":
+		browser.diffScroll = max(browser.diffScroll-10, 0)
+	case "pgdown":
+		browser.diffScroll = min(browser.diffScroll+10, m.conflictScrollMax())
+	}
+	return m, nil
+}
+
+// conflictHeight is the number of diff lines the conflict view shows at once
+func (m *TUIModel) conflictHeight() int {
+	return max(m.height-10, 5)
+}
+
+// conflictScrollMax is the furthest the conflict diff scrolls
+func (m *TUIModel) conflictScrollMax() int {
+	return max(len(m.files.conflictDiff)-m.conflictHeight(), 0)
+}
+
+// renderConflict shows how the remote file changed against the user's edit
+func (m *TUIModel) renderConflict() string {
+	edit := m.files.conflict
+
+	var result string
+	result += fmt.Sprintf("=== %s CHANGED ON THE SERVER ===\n\n", edit.path)
+	result += "The remote file was modified while you were editing it.\n\n"
+
+	lines := m.files.conflictDiff
+	height := m.conflictHeight()
+	start := min(m.files.diffScroll, max(len(lines)-height, 0))
+	end := min(start+height, len(lines))
+	for _, line := range lines[start:end] {
+		result += line + "\n"
+	}
+	if len(lines) > height {
+		result += fmt.Sprintf("\n[Lines %d-%d of %d] [↑↓/PgUp/PgDown to scroll]\n", start+1, end, len(lines))
+	}
+
+	result += "\n[o]verwrite with your edit [e]dit again [Esc] don't save\n"
+	return result
+}
+
+// renderDiffLine highlights one line of a unified diff
+func renderDiffLine(line string) string {
+	switch {
+	case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		return line
+	case strings.HasPrefix(line, "@@"):
+		return diffHunkStyle.Render(line)
+	case strings.HasPrefix(line, "+"):
+		return diffAddStyle.Render(line)
+	case strings.HasPrefix(line, "-"):
+		return diffDelStyle.Render(line)
+	default:
+		return line
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/SimonLariz/beacon/internal/model"
+	"github.com/SimonLariz/beacon/internal/ssh"
+)
+
+// Exit codes of `beacon exec` that don't come from the remote command
+const (
+	exitConnectFailed = 255 // Same as ssh when the connection fails
+	exitTimedOut      = 124 // Same as timeout(1)
+	exitInterrupted   = 130 // 128 + SIGINT
+)
+
+// Output formats of `beacon exec`
+const (
+	outputText   = "text"   // Stream the remote output as it arrives
+	outputTable  = "table"  // One summary row per connection
+	outputJSON   = "json"   // One array of records once every connection finished
+	outputNDJSON = "ndjson" // One record per line as each connection finishes
+)
+
+// execResult is the outcome of a headless command on one connection
+type execResult struct {
+	conn    *model.Connection
+	command string
+	started time.Time
+	ended   time.Time
+	result  *ssh.CommandResult // Nil when the command never ran
+	err     error              // Why the command never ran
+
+	stdout, stderr bytes.Buffer // Captured output for the structured formats
+}
+
+// record returns the result in machine-readable form
+func (r *execResult) record() model.ExecRecord {
+	rec := model.ExecRecord{
+		Alias:     r.conn.Alias,
+		Host:      r.conn.Address(),
+		Command:   r.command,
+		StartedAt: r.started,
+		Stdout:    r.stdout.String(),
+		Stderr:    r.stderr.String(),
+	}
+	switch {
+	case r.err != nil:
+		rec.DurationMS = r.ended.Sub(r.started).Milliseconds()
+		rec.Error = r.err.Error()
+	case r.result.Error != nil:
+		rec.DurationMS = r.result.Duration.Milliseconds()
+		rec.Error = r.errorText()
+	default:
+		rec.DurationMS = r.result.Duration.Milliseconds()
+		exitCode := r.result.ExitCode
+		rec.ExitCode = &exitCode
+	}
+	return rec
+}
+
+// errorText describes why the command didn't exit on its own, empty if it did
+func (r *execResult) errorText() string {
+	switch {
+	case r.err != nil:
+		return r.err.Error()
+	case errors.Is(r.result.Error, context.DeadlineExceeded):
+		return "timed out"
+	case r.result.Error != nil:
+		return "interrupted"
+	default:
+		return ""
+	}
+}
+
+// exitCode maps the outcome to a process exit code
+func (r *execResult) exitCode() int {
+	switch {
+	case r.err != nil:
+		return exitConnectFailed
+	case errors.Is(r.result.Error, context.DeadlineExceeded):
+		return exitTimedOut
+	case r.result.Error != nil:
+		return exitInterrupted
+	default:
+		return r.result.ExitCode
+	}
+}
+
+// runExec implements `beacon exec`
+// With text output a single connection streams its output unchanged, several run
+// in parallel with every line prefixed by the connection's alias
+// The table, json and ndjson formats capture the output into per-connection results
+// The exit code is the remote one, the highest across connections
+func runExec(args []string) int {
+	fs := flag.NewFlagSet("exec", flag.ContinueOnError)
+	timeout := fs.Duration("timeout", 0, "stop the command after this long, e.g. 30s (default: configured timeout)")
+	output := fs.String("output", outputText, "output format: text, table, json or ndjson")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	switch *output {
+	case outputText, outputTable, outputJSON, outputNDJSON:
+	default:
+		fmt.Fprintf(os.Stderr, "beacon: unknown output format %q, use text, table, json or ndjson\n", *output)
+		return 2
+	}
+
+	rest := fs.Args()
+	if len(rest) > 1 && rest[1] == "--" {
+		rest = append(rest[:1], rest[2:]...)
+	}
+	if len(rest) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: beacon exec [--timeout DURATION] [--output FORMAT] TARGET -- COMMAND...")
+		return 2
+	}
+	target, command := rest[0], strings.Join(rest[1:], " ")
+
+	config, err := model.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "beacon: %v\n", err)
+		return 1
+	}
+	conns := config.SelectConnections(target)
+	if len(conns) == 0 {
+		fmt.Fprintf(os.Stderr, "beacon: no connection matches %q\n", target)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var outMu sync.Mutex
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false) // Remote output is passed through as is
+	results := make([]*execResult, len(conns))
+	sem := make(chan struct{}, config.BroadcastConcurrencyFor())
+	var wg sync.WaitGroup
+	for i, conn := range conns {
+		r := &execResult{conn: conn, command: command}
+		results[i] = r
+
+		// Structured formats capture the output, text streams it
+		var stdout, stderr io.Writer = &r.stdout, &r.stderr
+		var flush func()
+		if *output == outputText {
+			stdout, stderr = os.Stdout, os.Stderr
+			if len(conns) > 1 {
+				prefix := fmt.Sprintf("[%s] ", conn.Alias)
+				out := &prefixWriter{mu: &outMu, w: os.Stdout, prefix: prefix}
+				errOut := &prefixWriter{mu: &outMu, w: os.Stderr, prefix: prefix}
+				stdout, stderr = out, errOut
+				flush = func() {
+					out.Flush()
+					errOut.Flush()
+				}
+			}
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			commandTimeout := *timeout
+			if commandTimeout == 0 {
+				commandTimeout = config.CommandTimeoutFor(conn)
+			}
+			execOn(ctx, config, r, commandTimeout, stdout, stderr)
+			if flush != nil {
+				flush()
+			}
+			if *output == outputNDJSON {
+				outMu.Lock()
+				encoder.Encode(r.record())
+				outMu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	switch *output {
+	case outputText:
+		for _, r := range results {
+			if text := r.errorText(); text != "" {
+				fmt.Fprintf(os.Stderr, "beacon: %s: %s\n", r.conn.Alias, text)
+			}
+		}
+	case outputTable:
+		printExecTable(results)
+	case outputJSON:
+		records := make([]model.ExecRecord, 0, len(results))
+		for _, r := range results {
+			records = append(records, r.record())
+		}
+		encoder.SetIndent("", "  ")
+		encoder.Encode(records)
+	}
+
+	exitCode := 0
+	for _, r := range results {
+		exitCode = max(exitCode, r.exitCode())
+	}
+	return exitCode
+}
+
+// printExecTable prints one summary row per connection
+func printExecTable(results []*execResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ALIAS\tHOST\tEXIT\tDURATION\tRESULT")
+	for _, r := range results {
+		rec := r.record()
+		exitCode := "-"
+		if rec.ExitCode != nil {
+			exitCode = fmt.Sprintf("%d", *rec.ExitCode)
+		}
+		summary := rec.Error
+		if summary == "" {
+			summary = lastLine(rec.Stdout)
+		}
+		if summary == "" {
+			summary = lastLine(rec.Stderr)
+		}
+		duration := (time.Duration(rec.DurationMS) * time.Millisecond).String()
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", rec.Alias, rec.Host, exitCode, duration, summary)
+	}
+	w.Flush()
+}
+
+// lastLine returns the last non-empty line of text, shortened for a table cell
+func lastLine(text string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	return truncate(strings.TrimSpace(lines[len(lines)-1]), 60)
+}
+
+// execOn connects to the result's connection, runs its command and disconnects
+// Unknown host keys are rejected and nothing is prompted for, so it can run unattended
+// A command that ran is added to the connection's history like one run from the TUI
+func execOn(ctx context.Context, config *model.Config, r *execResult,
+	timeout time.Duration, stdout, stderr io.Writer) {
+	conn := r.conn
+	r.started = time.Now()
+	defer func() { r.ended = time.Now() }()
+
+	opts := conn.ConnectOptions()
+	opts.JumpHosts, r.err = config.ResolveJumps(conn)
+	if r.err != nil {
+		return
+	}
+	client, err := ssh.Connect(conn.Host, conn.Port, conn.User, conn.KeyPath, opts)
+	if err != nil {
+		r.err = err
+		return
+	}
+	defer client.Disconnect()
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	execution := model.NewCommandExecution(r.command)
+	execution.Timeout = timeout
+	r.result, r.err = client.ExecuteCommandStream(ctx, r.command, func(chunk ssh.OutputChunk) {
+		execution.AppendOutput(chunk.Stderr, string(chunk.Data))
+		if chunk.Stderr {
+			stderr.Write(chunk.Data)
+		} else {
+			stdout.Write(chunk.Data)
+		}
+	})
+	if r.err != nil || config.HistoryRetention().Limit == 0 {
+		return
+	}
+	execution.Finish(r.result.ExitCode, r.result.Duration, r.result.Error)
+	if err := model.AppendHistory(conn.Alias, execution); err != nil {
+		fmt.Fprintf(os.Stderr, "beacon: %s: failed to log execution: %v\n", conn.Alias, err)
+	}
+}
+
+// prefixWriter writes whole lines with a prefix, so output of parallel
+// connections doesn't interleave mid-line
+type prefixWriter struct {
+	mu      *sync.Mutex // Shared by every writer of the same output
+	w       io.Writer
+	prefix  string
+	partial []byte // Start of a line whose newline hasn't arrived yet
+}
+
+func (p *prefixWriter) Write(data []byte) (int, error) {
+	p.partial = append(p.partial, data...)
+	for {
+		i := bytes.IndexByte(p.partial, '\n')
+		if i < 0 {
+			return len(data), nil
+		}
+		p.writeLine(p.partial[:i+1])
+		p.partial = p.partial[i+1:]
+	}
+}
+
+// Flush writes an unterminated last line
+func (p *prefixWriter) Flush() {
+	if len(p.partial) > 0 {
+		p.writeLine(append(p.partial, '\n'))
+		p.partial = nil
+	}
+}
+
+func (p *prefixWriter) writeLine(line []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	io.WriteString(p.w, p.prefix)
+	p.w.Write(line)
+}
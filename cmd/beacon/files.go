@@ -0,0 +1,624 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/SimonLariz/beacon/internal/model"
+	"github.com/SimonLariz/beacon/internal/ssh"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// transferRefreshInterval is how often progress bars update while transfers run
+const transferRefreshInterval = 200 * time.Millisecond
+
+// fileSystem is one side of the file browser, the local disk or the sftp session
+type fileSystem interface {
+	ReadDir(dir string) ([]ssh.FileInfo, error)
+	Rename(oldname, newname string) error
+	Remove(name string) error
+	Mkdir(name string) error
+	Join(elem ...string) string
+}
+
+// localFS is the local side of the file browser
+type localFS struct{}
+
+func (localFS) ReadDir(dir string) ([]ssh.FileInfo, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]ssh.FileInfo, 0, len(dirEntries))
+	for _, entry := range dirEntries {
+		fi, err := entry.Info()
+		if err != nil {
+			// Removed while listing
+			continue
+		}
+		entries = append(entries, ssh.NewFileInfo(fi))
+	}
+	ssh.SortFileInfos(entries)
+	return entries, nil
+}
+
+func (localFS) Rename(oldname, newname string) error { return os.Rename(oldname, newname) }
+func (localFS) Remove(name string) error             { return os.Remove(name) }
+func (localFS) Mkdir(name string) error              { return os.Mkdir(name, 0755) }
+func (localFS) Join(elem ...string) string           { return filepath.Join(elem...) }
+
+// filePane is one directory listing of the file browser
+type filePane struct {
+	fs      fileSystem
+	title   string
+	dir     string
+	entries []ssh.FileInfo
+	index   int
+	latest  int // Sequence number of the newest listing asked for, older ones are dropped
+	pending int // Operations and listings still running in the background
+}
+
+// paneLoadedMsg carries the result of a pane operation and the listing read after it
+type paneLoadedMsg struct {
+	pane    *filePane
+	seq     int
+	dir     string
+	entries []ssh.FileInfo
+	listErr error  // Why dir couldn't be listed
+	opErr   error  // Why the operation failed
+	done    string // Status shown when the operation succeeded
+	focus   string // Entry to put the cursor on, empty keeps the cursor where it is
+}
+
+// load lists dir in the background, the listing replaces the current one once
+// it arrives and is dropped if it can't be read
+func (p *filePane) load(dir string) tea.Cmd {
+	return p.run(nil, dir, "", "")
+}
+
+// run performs op in the background then lists dir again, so a slow or
+// stalled sftp server never blocks the UI
+// done is the status shown when op succeeds, focus the entry selected afterwards
+func (p *filePane) run(op func() error, dir, done, focus string) tea.Cmd {
+	p.latest++
+	p.pending++
+	seq, fs := p.latest, p.fs
+	return func() tea.Msg {
+		msg := paneLoadedMsg{pane: p, seq: seq, dir: dir, done: done, focus: focus}
+		if op != nil {
+			msg.opErr = op()
+		}
+		msg.entries, msg.listErr = fs.ReadDir(dir)
+		return msg
+	}
+}
+
+// apply shows a listing of dir, keeping the cursor when the directory didn't change
+func (p *filePane) apply(dir string, entries []ssh.FileInfo, focus string) {
+	if dir != p.dir {
+		p.index = 0
+	}
+	p.dir = dir
+	p.entries = entries
+	for i, entry := range entries {
+		if focus != "" && entry.Name == focus {
+			p.index = i
+			break
+		}
+	}
+	if p.index >= len(p.entries) {
+		p.index = len(p.entries) - 1
+	}
+	if p.index < 0 {
+		p.index = 0
+	}
+}
+
+// selected returns the entry under the cursor, nil for an empty directory
+func (p *filePane) selected() *ssh.FileInfo {
+	if p.index < 0 || p.index >= len(p.entries) {
+		return nil
+	}
+	return &p.entries[p.index]
+}
+
+// path returns the full path of an entry in the pane's directory
+func (p *filePane) path(name string) string {
+	return p.fs.Join(p.dir, name)
+}
+
+// fileTransfer is an upload or download running in the background
+type fileTransfer struct {
+	name   string
+	upload bool
+	done   atomic.Int64 // Written by the transfer goroutine
+	total  atomic.Int64
+	cancel context.CancelFunc
+}
+
+// fileInputKind is what the browser's input line is being used for
+type fileInputKind int
+
+const (
+	fileInputNone fileInputKind = iota
+	fileInputRename
+	fileInputMkdir
+	fileInputDelete // Waiting for y/n
+)
+
+// fileBrowser is the state of the two-pane sftp browser
+type fileBrowser struct {
+	state     *model.ConnectionState
+	client    *ssh.SFTPClient
+	panes     [2]*filePane // Local, remote
+	active    int
+	inputKind fileInputKind
+	input     string
+	transfers []*fileTransfer
+
+	conflict     *remoteEdit // Edit waiting for a decision, the remote file changed under it
+	conflictDiff []string    // Rendered diff of the conflict, computed once when it is found
+	diffScroll   int         // Scroll position of the conflict diff
+}
+
+// sftpOpenedMsg is sent once the sftp session of the browser is ready
+type sftpOpenedMsg struct {
+	state   *model.ConnectionState
+	client  *ssh.SFTPClient
+	home    string
+	entries []ssh.FileInfo // Listing of home
+	listErr error
+	err     error
+}
+
+// transferDoneMsg is sent when an upload or download ends
+type transferDoneMsg struct {
+	transfer *fileTransfer
+	err      error
+}
+
+// transferTickMsg refreshes progress bars while transfers run
+type transferTickMsg struct{}
+
+// transferTick schedules the next progress refresh
+func transferTick() tea.Cmd {
+	return tea.Tick(transferRefreshInterval, func(time.Time) tea.Msg {
+		return transferTickMsg{}
+	})
+}
+
+// openFiles starts an sftp session on the selected server for the file browser
+func (m *TUIModel) openFiles() tea.Cmd {
+	selected := m.AppState.GetSelected()
+	if selected == nil || selected.Client == nil || !selected.Client.IsConnected() {
+		m.setStatus("No connected server selected", 2*time.Second)
+		return nil
+	}
+
+	client := selected.Client
+	m.setStatus(fmt.Sprintf("Starting sftp on %s...", selected.Connection.Alias), 10*time.Second)
+	return func() tea.Msg {
+		sftpClient, err := client.OpenSFTP()
+		if err != nil {
+			return sftpOpenedMsg{state: selected, err: err}
+		}
+		home, err := sftpClient.Getwd()
+		if err != nil {
+			home = "/"
+		}
+		entries, err := sftpClient.ReadDir(home)
+		return sftpOpenedMsg{state: selected, client: sftpClient, home: home, entries: entries, listErr: err}
+	}
+}
+
+// sftpOpened shows the file browser once its session is ready
+func (m *TUIModel) sftpOpened(msg sftpOpenedMsg) tea.Cmd {
+	if msg.err != nil {
+		m.setStatus(fmt.Sprintf("Error: %v", msg.err), 5*time.Second)
+		return nil
+	}
+	if m.AppState.GetSelected() != msg.state || m.mode != ModeNormal {
+		// The user moved on while the session was starting
+		msg.client.Close()
+		return nil
+	}
+
+	localDir, err := os.Getwd()
+	if err != nil {
+		localDir, _ = os.UserHomeDir()
+	}
+
+	browser := &fileBrowser{
+		state:  msg.state,
+		client: msg.client,
+		panes: [2]*filePane{
+			{fs: localFS{}, title: "local"},
+			{fs: msg.client, title: msg.state.Connection.Alias},
+		},
+		active: 1,
+	}
+	if msg.listErr != nil {
+		m.setStatus(fmt.Sprintf("Error: %v", msg.listErr), 5*time.Second)
+	} else {
+		browser.panes[1].apply(msg.home, msg.entries, "")
+		m.statusTimeout = time.Time{}
+	}
+
+	m.files = browser
+	m.mode = ModeFiles
+	return browser.panes[0].load(localDir)
+}
+
+// paneLoaded reports a finished pane operation and shows the listing read after it
+func (m *TUIModel) paneLoaded(msg paneLoadedMsg) {
+	browser := m.files
+	if browser == nil || (msg.pane != browser.panes[0] && msg.pane != browser.panes[1]) {
+		// The browser was closed meanwhile
+		return
+	}
+	pane := msg.pane
+	pane.pending--
+
+	switch {
+	case msg.opErr != nil:
+		m.setStatus(fmt.Sprintf("Error: %v", msg.opErr), 5*time.Second)
+	case msg.listErr != nil:
+		m.setStatus(fmt.Sprintf("Error: %v", msg.listErr), 5*time.Second)
+	case msg.done != "":
+		m.setStatus(msg.done, 3*time.Second)
+	}
+	// A newer listing is on its way, this one is already out of date
+	if msg.listErr == nil && msg.seq == pane.latest {
+		pane.apply(msg.dir, msg.entries, msg.focus)
+	}
+}
+
+// closeFiles leaves the file browser, cancelling running transfers
+func (m *TUIModel) closeFiles() {
+	if m.files != nil {
+		for _, t := range m.files.transfers {
+			t.cancel()
+		}
+		if edit := m.files.conflict; edit != nil {
+			m.setStatus(fmt.Sprintf("%s was not saved, your copy is in %s", edit.name(), edit.localPath), 10*time.Second)
+		}
+		m.files.client.Close()
+		m.files = nil
+	}
+	m.mode = ModeNormal
+}
+
+// transferDone reports a finished transfer and refreshes its destination
+func (m *TUIModel) transferDone(msg transferDoneMsg) tea.Cmd {
+	browser := m.files
+	if browser == nil {
+		return nil
+	}
+	for i, t := range browser.transfers {
+		if t == msg.transfer {
+			browser.transfers = append(browser.transfers[:i], browser.transfers[i+1:]...)
+			break
+		}
+	}
+
+	verb := "Downloaded"
+	dest := browser.panes[0]
+	if msg.transfer.upload {
+		verb = "Uploaded"
+		dest = browser.panes[1]
+	}
+	switch {
+	case errors.Is(msg.err, context.Canceled):
+		m.setStatus(fmt.Sprintf("Cancelled transfer of %s", msg.transfer.name), 3*time.Second)
+	case msg.err != nil:
+		m.setStatus(fmt.Sprintf("Transfer of %s failed: %v", msg.transfer.name, msg.err), 5*time.Second)
+	default:
+		m.setStatus(fmt.Sprintf("%s %s", verb, msg.transfer.name), 3*time.Second)
+	}
+	return dest.load(dest.dir)
+}
+
+// startTransfer copies the selected file to the directory of the other pane
+func (m *TUIModel) startTransfer() tea.Cmd {
+	browser := m.files
+	src := browser.panes[browser.active]
+	dst := browser.panes[1-browser.active]
+	entry := src.selected()
+	if entry == nil {
+		return nil
+	}
+	if entry.IsDir {
+		m.setStatus("Only files can be transferred", 3*time.Second)
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	transfer := &fileTransfer{name: entry.Name, upload: browser.active == 0, cancel: cancel}
+	transfer.total.Store(entry.Size)
+	browser.transfers = append(browser.transfers, transfer)
+
+	client := browser.client
+	srcPath := src.path(entry.Name)
+	dstPath := dst.path(entry.Name)
+	progress := func(done, total int64) {
+		transfer.done.Store(done)
+		transfer.total.Store(total)
+	}
+
+	run := func() tea.Msg {
+		defer cancel()
+		var err error
+		if transfer.upload {
+			err = client.Upload(ctx, srcPath, dstPath, progress)
+		} else {
+			err = client.Download(ctx, srcPath, dstPath, progress)
+		}
+		return transferDoneMsg{transfer: transfer, err: err}
+	}
+
+	if len(browser.transfers) > 1 {
+		// The running transfers already keep the refresh going
+		return run
+	}
+	return tea.Batch(run, transferTick())
+}
+
+// handleFilesKey processes key input in the file browser
+func (m *TUIModel) handleFilesKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	browser := m.files
+	if browser == nil {
+		m.mode = ModeNormal
+		return m, nil
+	}
+	pane := browser.panes[browser.active]
+
+	if browser.conflict != nil {
+		return m.handleConflictKey(msg)
+	}
+	if browser.inputKind != fileInputNone {
+		return m.handleFilesInput(msg)
+	}
+
+	switch msg.String() {
+	case "esc", "q", "b":
+		m.closeFiles()
+	case "ctrl+c":
+		return m, tea.Quit
+	case "tab":
+		browser.active = 1 - browser.active
+	case "up":
+		if pane.index > 0 {
+			pane.index--
+		}
+	case "down":
+		if pane.index < len(pane.entries)-1 {
+			pane.index++
+		}
+	case "enter":
+		if entry := pane.selected(); entry != nil && entry.IsDir {
+			return m, pane.load(pane.path(entry.Name))
+		}
+	case "backspace":
+		return m, pane.load(pane.path(".."))
+	case "R":
+		return m, pane.load(pane.dir)
+	case "c":
+		return m, m.startTransfer()
+	case "e":
+		return m, m.startEdit(false)
+	case "E":
+		return m, m.startEdit(true)
+	case "x":
+		if n := len(browser.transfers); n > 0 {
+			browser.transfers[n-1].cancel()
+		}
+	case "r":
+		if entry := pane.selected(); entry != nil {
+			browser.inputKind = fileInputRename
+			browser.input = entry.Name
+		}
+	case "m":
+		browser.inputKind = fileInputMkdir
+		browser.input = ""
+	case "d":
+		if pane.selected() != nil {
+			browser.inputKind = fileInputDelete
+		}
+	}
+	return m, nil
+}
+
+// handleFilesInput processes key input while renaming, creating a directory or confirming a delete
+func (m *TUIModel) handleFilesInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	browser := m.files
+	pane := browser.panes[browser.active]
+
+	if browser.inputKind == fileInputDelete {
+		browser.inputKind = fileInputNone
+		if entry := pane.selected(); entry != nil && msg.String() == "y" {
+			path := pane.path(entry.Name)
+			return m, pane.run(func() error { return pane.fs.Remove(path) }, pane.dir,
+				fmt.Sprintf("Deleted %s", entry.Name), "")
+		}
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "esc":
+		browser.inputKind = fileInputNone
+		browser.input = ""
+	case "enter":
+		name := strings.TrimSpace(browser.input)
+		if name == "" {
+			return m, nil
+		}
+		var op func() error
+		if browser.inputKind == fileInputRename {
+			if entry := pane.selected(); entry != nil && entry.Name != name {
+				oldPath, newPath := pane.path(entry.Name), pane.path(name)
+				op = func() error { return pane.fs.Rename(oldPath, newPath) }
+			}
+		} else {
+			path := pane.path(name)
+			op = func() error { return pane.fs.Mkdir(path) }
+		}
+		browser.inputKind = fileInputNone
+		browser.input = ""
+		if op != nil {
+			return m, pane.run(op, pane.dir, "", name)
+		}
+	case "backspace":
+		if len(browser.input) > 0 {
+			browser.input = browser.input[:len(browser.input)-1]
+		}
+	default:
+		if len(msg.String()) == 1 {
+			browser.input += msg.String()
+		}
+	}
+	return m, nil
+}
+
+// renderFiles renders the two-pane file browser
+func (m *TUIModel) renderFiles() string {
+	browser := m.files
+	if browser == nil {
+		return ""
+	}
+	if browser.conflict != nil {
+		return m.renderConflict()
+	}
+
+	var result string
+	result += fmt.Sprintf("=== FILES (%s) ===\n\n", browser.state.Connection.Alias)
+
+	width := m.width
+	if width < 40 {
+		width = 80
+	}
+	paneWidth := width/2 - 1
+	paneHeight := m.height - 14
+	if paneHeight < 5 {
+		paneHeight = 5
+	}
+
+	left := renderFilePane(browser.panes[0], browser.active == 0, paneWidth, paneHeight)
+	right := renderFilePane(browser.panes[1], browser.active == 1, paneWidth, paneHeight)
+	result += lipgloss.JoinHorizontal(lipgloss.Top, left, " ", right) + "\n"
+
+	// Metadata of the selected entry
+	if entry := browser.panes[browser.active].selected(); entry != nil {
+		size := formatBytes(entry.Size)
+		if entry.IsDir {
+			size = "dir"
+		}
+		result += fmt.Sprintf("\n%s  %s  %s  %s\n", entry.Mode, size,
+			entry.ModTime.Format("2006-01-02 15:04"), entry.Name)
+	}
+
+	for _, t := range browser.transfers {
+		arrow := "↓"
+		if t.upload {
+			arrow = "↑"
+		}
+		done, total := t.done.Load(), t.total.Load()
+		result += fmt.Sprintf("%s %s %s %s/%s\n", arrow, progressBar(done, total, 30), t.name,
+			formatBytes(done), formatBytes(total))
+	}
+
+	switch browser.inputKind {
+	case fileInputRename:
+		result += "\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n"
+		result += fmt.Sprintf("Rename to: %s█\n[Enter] rename [Esc] cancel\n", browser.input)
+	case fileInputMkdir:
+		result += "\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n"
+		result += fmt.Sprintf("New directory: %s█\n[Enter] create [Esc] cancel\n", browser.input)
+	case fileInputDelete:
+		entry := browser.panes[browser.active].selected()
+		if entry != nil {
+			result += fmt.Sprintf("\nDelete %s? [y/N]\n", entry.Name)
+		}
+	default:
+		result += "\n[Tab] switch pane [Enter] open [Backspace] up [c]opy [e]dit [E]dit as root [r]ename [d]elete [m]kdir [x] cancel transfer [R]efresh [Esc] back\n"
+	}
+
+	if time.Now().Before(m.statusTimeout) {
+		result += fmt.Sprintf("\n%s\n", m.statusMessage)
+	}
+	return result
+}
+
+// renderFilePane renders one directory listing, scrolled to keep the cursor visible
+func renderFilePane(pane *filePane, active bool, width, height int) string {
+	title := fmt.Sprintf("%s: %s", pane.title, pane.dir)
+	if pane.pending > 0 {
+		title += " (working…)"
+	}
+	if active {
+		title = "▶ " + title
+	} else {
+		title = "  " + title
+	}
+
+	lines := []string{truncate(title, width), strings.Repeat("─", width)}
+	if len(pane.entries) == 0 {
+		lines = append(lines, "  (empty)")
+	}
+
+	start := 0
+	if pane.index >= height {
+		start = pane.index - height + 1
+	}
+	for i := start; i < len(pane.entries) && i < start+height; i++ {
+		entry := pane.entries[i]
+		marker := "  "
+		if i == pane.index && active {
+			marker = "> "
+		} else if i == pane.index {
+			marker = "· "
+		}
+		name := entry.Name
+		if entry.IsDir {
+			name += "/"
+		}
+		size := ""
+		if !entry.IsDir {
+			size = formatBytes(entry.Size)
+		}
+		nameWidth := width - len(marker) - 11
+		lines = append(lines, fmt.Sprintf("%s%-*s %10s", marker, nameWidth, truncate(name, nameWidth), size))
+	}
+	return lipgloss.NewStyle().Width(width).Render(strings.Join(lines, "\n"))
+}
+
+// progressBar renders a text progress bar of the given width
+func progressBar(done, total int64, width int) string {
+	if total <= 0 {
+		return "[" + strings.Repeat("?", width) + "]"
+	}
+	filled := int(done * int64(width) / total)
+	if filled > width {
+		filled = width
+	}
+	percent := done * 100 / total
+	return fmt.Sprintf("[%s%s] %3d%%", strings.Repeat("#", filled), strings.Repeat(".", width-filled), percent)
+}
+
+// truncate shortens s to at most width runes, marking the cut with an ellipsis
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if width <= 0 {
+		return ""
+	}
+	if len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-1]) + "…"
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/SimonLariz/beacon/internal/model"
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// formLabelStyle dims the hint next to each form field
+var formLabelStyle = lipgloss.NewStyle().Faint(true)
+
+// formInputWidth is how many characters of a field show before it scrolls
+const formInputWidth = 48
+
+// formField is one input of the connection form
+type formField struct {
+	name  string
+	label string
+	hint  string
+	input textinput.Model
+	err   string // Why the value is invalid, empty when it is fine
+	shown bool   // Whether errors are shown yet, set once the field was left
+}
+
+// AddConnectionForm holds the input fields for adding or editing a connection
+type AddConnectionForm struct {
+	fields []*formField
+	active int
+
+	editing *model.Connection // Connection being edited, nil when adding one
+	err     string            // Why the form couldn't be saved
+}
+
+// NewAddConnectionForm creates a new AddConnectionForm
+func NewAddConnectionForm() *AddConnectionForm {
+	f := &AddConnectionForm{}
+	add := func(name, label, hint, placeholder string) {
+		input := textinput.New()
+		input.Prompt = ""
+		input.Placeholder = placeholder
+		input.Width = formInputWidth
+		input.Cursor.SetMode(cursor.CursorStatic)
+		f.fields = append(f.fields, &formField{name: name, label: label, hint: hint, input: input})
+	}
+	add("alias", "Alias", "nickname", "web-1")
+	add("host", "Host", "IP, hostname or ssh config alias", "10.0.0.5")
+	add("user", "User", "blank: ssh config", "")
+	add("port", "Port", "blank: ssh config or 22", "")
+	add("key_path", "Key Path", "optional", "~/.ssh/id_ed25519")
+	add("jumps", "Jump hosts", "optional, comma separated aliases or user@host:port", "")
+	add("group", "Group", "optional", "prod/db")
+	add("tags", "Tags", "optional, comma separated", "web,env=prod")
+	f.focus(0)
+	return f
+}
+
+// NewEditConnectionForm creates a form pre-filled from an existing connection
+func NewEditConnectionForm(conn *model.Connection) *AddConnectionForm {
+	f := NewAddConnectionForm()
+	f.editing = conn
+	f.field("alias").input.SetValue(conn.Alias)
+	f.field("host").input.SetValue(conn.Host)
+	f.field("user").input.SetValue(conn.User)
+	if conn.Port != 0 {
+		f.field("port").input.SetValue(strconv.Itoa(conn.Port))
+	}
+	f.field("key_path").input.SetValue(conn.KeyPath)
+	f.field("jumps").input.SetValue(strings.Join(conn.Jumps, ", "))
+	f.field("group").input.SetValue(conn.Group)
+	f.field("tags").input.SetValue(strings.Join(conn.Tags, ","))
+	return f
+}
+
+// field returns the field with the given name
+func (f *AddConnectionForm) field(name string) *formField {
+	for _, field := range f.fields {
+		if field.name == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// value returns the trimmed value of a field
+func (f *AddConnectionForm) value(name string) string {
+	return strings.TrimSpace(f.field(name).input.Value())
+}
+
+// focus moves the cursor to a field
+func (f *AddConnectionForm) focus(index int) {
+	f.fields[f.active].input.Blur()
+	f.active = index
+	f.fields[f.active].input.Focus()
+}
+
+// NextField moves to the next field, showing errors of the one left
+func (f *AddConnectionForm) NextField(config *model.Config) {
+	f.leave(config)
+	f.focus((f.active + 1) % len(f.fields))
+}
+
+// PrevField moves to the previous field, showing errors of the one left
+func (f *AddConnectionForm) PrevField(config *model.Config) {
+	f.leave(config)
+	f.focus((f.active - 1 + len(f.fields)) % len(f.fields))
+}
+
+// leave validates the active field before the cursor moves away
+// Errors stay hidden while a field is first typed in
+func (f *AddConnectionForm) leave(config *model.Config) {
+	field := f.fields[f.active]
+	field.shown = true
+	f.validate(field, config)
+}
+
+// Update passes a message to the active input, revalidating it once its errors are shown
+func (f *AddConnectionForm) Update(msg tea.Msg, config *model.Config) tea.Cmd {
+	field := f.fields[f.active]
+	var cmd tea.Cmd
+	field.input, cmd = field.input.Update(msg)
+	if field.shown {
+		f.validate(field, config)
+	}
+	f.err = ""
+	return cmd
+}
+
+// validate checks a field's value, setting its error
+func (f *AddConnectionForm) validate(field *formField, config *model.Config) {
+	value := f.value(field.name)
+	var err error
+	switch field.name {
+	case "alias":
+		err = config.ValidateAlias(value, f.editing)
+	case "host":
+		err = model.ValidateHost(value)
+	case "user":
+		err = model.ValidateUser(value)
+	case "port":
+		_, err = model.ParsePort(value)
+	case "key_path":
+		err = model.ValidateKeyPath(value)
+	case "jumps":
+		for _, hop := range splitList(value) {
+			if err = config.ValidateJump(hop); err != nil {
+				break
+			}
+		}
+	}
+	field.err = ""
+	if err != nil {
+		field.err = err.Error()
+	}
+}
+
+// splitList splits a comma separated value, dropping blank entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// connection builds the connection the form describes, checking every field
+// On error the cursor moves to the first invalid field
+func (f *AddConnectionForm) connection(config *model.Config) (*model.Connection, error) {
+	invalid := -1
+	for i, field := range f.fields {
+		field.shown = true
+		f.validate(field, config)
+		if field.err != "" && invalid < 0 {
+			invalid = i
+		}
+	}
+	if invalid >= 0 {
+		f.focus(invalid)
+		field := f.fields[invalid]
+		return nil, fmt.Errorf("%s: %s", field.label, field.err)
+	}
+
+	port, _ := model.ParsePort(f.value("port"))
+	conn := model.NewConnection(f.value("alias"), f.value("host"), f.value("user"), port)
+	conn.KeyPath = f.value("key_path")
+	conn.Jumps = splitList(f.value("jumps"))
+	conn.Group = model.NormalizeGroup(f.value("group"))
+	conn.Tags = model.ParseTags(f.value("tags"))
+	if _, err := config.ResolveJumps(conn); err != nil {
+		// Each hop is fine on its own, the chain loops
+		for i, field := range f.fields {
+			if field.name == "jumps" {
+				field.err = err.Error()
+				f.focus(i)
+			}
+		}
+		return nil, err
+	}
+	return conn, nil
+}
+
+// handleFormKey processes key input in the add/edit connection form
+func (m *TUIModel) handleFormKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.mode = ModeNormal
+		m.form = NewAddConnectionForm()
+	case "tab", "down":
+		m.form.NextField(m.AppState.Config)
+	case "shift+tab", "up":
+		m.form.PrevField(m.AppState.Config)
+	case "enter":
+		m.submitForm()
+	default:
+		// Editing keys, cursor movement and pastes go to the active input
+		return m, m.form.Update(msg, m.AppState.Config)
+	}
+	return m, nil
+}
+
+// renderAddForm renders the add/edit connection form
+func (m *TUIModel) renderAddForm() string {
+	var result string
+	if m.form.editing != nil {
+		result += fmt.Sprintf("=== EDIT CONNECTION: %s ===\n\n", m.form.editing.Alias)
+	} else {
+		result += "=== ADD NEW CONNECTION ===\n\n"
+	}
+
+	for i, field := range m.form.fields {
+		prefix := "  "
+		if i == m.form.active {
+			prefix = "> " // Highlight active field
+		}
+		result += fmt.Sprintf("%s%s %s: %s\n", prefix, field.label, formLabelStyle.Render("("+field.hint+")"),
+			field.input.View())
+		if field.shown && field.err != "" {
+			result += "    " + stderrStyle.Render("✗ "+field.err) + "\n"
+		}
+	}
+	if m.form.err != "" {
+		result += "\n" + stderrStyle.Render("Error: "+m.form.err) + "\n"
+	}
+
+	result += "\n[Tab/↓]next [Shift+Tab/↑]prev [←→ Home End] move [Ctrl+V]paste [Enter]save [Esc]cancel\n"
+	return result
+}
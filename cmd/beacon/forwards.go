@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/SimonLariz/beacon/internal/model"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// forwardRefreshInterval is how often the forwards panel updates its counters
+const forwardRefreshInterval = time.Second
+
+// forwardTickMsg refreshes the forwards panel while it is open
+type forwardTickMsg struct{}
+
+// forwardTick schedules the next panel refresh
+func forwardTick() tea.Cmd {
+	return tea.Tick(forwardRefreshInterval, func(time.Time) tea.Msg {
+		return forwardTickMsg{}
+	})
+}
+
+// startForwards starts the configured forwards of a freshly connected server
+func (m *TUIModel) startForwards(cs *model.ConnectionState) {
+	cs.StartForwards()
+	if failed := cs.FailedForwards(); failed > 0 {
+		m.setStatus(fmt.Sprintf("%d port forward(s) on %s failed to start, press f for details",
+			failed, cs.Connection.Alias), 5*time.Second)
+	}
+}
+
+// openForwards shows the forwards panel for the selected connection
+func (m *TUIModel) openForwards() tea.Cmd {
+	if m.AppState.GetSelected() == nil {
+		return nil
+	}
+	m.mode = ModeForwards
+	m.forwardIndex = 0
+	m.forwardAdding = false
+	m.forwardInput = ""
+	return forwardTick()
+}
+
+// handleForwardsKey processes key input in the forwards panel
+func (m *TUIModel) handleForwardsKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	cs := m.AppState.GetSelected()
+	if cs == nil {
+		m.mode = ModeNormal
+		return m, nil
+	}
+
+	if m.forwardAdding {
+		switch msg.String() {
+		case "esc":
+			m.forwardAdding = false
+			m.forwardInput = ""
+		case "enter":
+			state, err := cs.AddForward(m.forwardInput)
+			if err != nil {
+				m.setStatus(fmt.Sprintf("Error: %v", err), 5*time.Second)
+				return m, nil
+			}
+			if err := model.SaveConfig(m.AppState.Config); err != nil {
+				log.Printf("Warning: failed to save config: %v", err)
+			}
+			switch {
+			case state == nil:
+				m.setStatus("Forward added, it starts on the next connect", 3*time.Second)
+			case state.Err != nil:
+				m.setStatus(fmt.Sprintf("Forward added but failed to start: %v", state.Err), 5*time.Second)
+			default:
+				m.setStatus(fmt.Sprintf("Forwarding %s", state.Spec), 3*time.Second)
+			}
+			m.forwardAdding = false
+			m.forwardInput = ""
+			m.forwardIndex = len(cs.Connection.Forwards) - 1
+		case "backspace":
+			if len(m.forwardInput) > 0 {
+				m.forwardInput = m.forwardInput[:len(m.forwardInput)-1]
+			}
+		default:
+			if len(msg.String()) == 1 {
+				m.forwardInput += msg.String()
+			}
+		}
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "esc", "f", "q":
+		m.mode = ModeNormal
+	case "ctrl+c":
+		return m, tea.Quit
+	case "up":
+		if m.forwardIndex > 0 {
+			m.forwardIndex--
+		}
+	case "down":
+		if m.forwardIndex < len(cs.Connection.Forwards)-1 {
+			m.forwardIndex++
+		}
+	case "a":
+		m.forwardAdding = true
+		m.forwardInput = ""
+	case "d":
+		if m.forwardIndex < 0 || m.forwardIndex >= len(cs.Connection.Forwards) {
+			return m, nil
+		}
+		spec := cs.Connection.Forwards[m.forwardIndex]
+		if err := cs.RemoveForward(spec); err != nil {
+			m.setStatus(fmt.Sprintf("Error: %v", err), 5*time.Second)
+			return m, nil
+		}
+		if err := model.SaveConfig(m.AppState.Config); err != nil {
+			log.Printf("Warning: failed to save config: %v", err)
+		}
+		m.setStatus(fmt.Sprintf("Removed forward %s", spec), 3*time.Second)
+		if m.forwardIndex >= len(cs.Connection.Forwards) && m.forwardIndex > 0 {
+			m.forwardIndex--
+		}
+	}
+	return m, nil
+}
+
+// renderForwards renders the forwards panel of the selected connection
+func (m *TUIModel) renderForwards() string {
+	cs := m.AppState.GetSelected()
+	if cs == nil {
+		return ""
+	}
+
+	var result string
+	result += fmt.Sprintf("=== PORT FORWARDS (%s) ===\n\n", cs.Connection.Alias)
+
+	if len(cs.Connection.Forwards) == 0 {
+		result += "(No forwards. Press 'a' to add one.)\n"
+	}
+
+	for i, spec := range cs.Connection.Forwards {
+		marker := "  "
+		if i == m.forwardIndex {
+			marker = "> "
+		}
+
+		state := cs.ForwardState(spec)
+		switch {
+		case state == nil:
+			result += fmt.Sprintf("%s[%d] %s - inactive\n", marker, i, spec)
+		case state.Err != nil:
+			result += fmt.Sprintf("%s[%d] %s - failed\n", marker, i, spec)
+			result += fmt.Sprintf("     Error: %v\n", state.Err)
+		default:
+			stats := state.Stats()
+			status := "active"
+			if stats.Closed {
+				status = "closed"
+			}
+			result += fmt.Sprintf("%s[%d] %s - %s, %d conn(s) (%d open), ↑ %s ↓ %s\n",
+				marker, i, spec, status, stats.Total, stats.Active,
+				formatBytes(stats.BytesSent), formatBytes(stats.BytesReceived))
+			if stats.LastError != nil {
+				result += fmt.Sprintf("     Last error: %v\n", stats.LastError)
+			}
+		}
+	}
+
+	if m.forwardAdding {
+		result += "\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n"
+		result += fmt.Sprintf("Forward: %s█\n", m.forwardInput)
+		result += "L [bind:]port:host:hostport | R [bind:]port:host:hostport | D [bind:]port\n"
+		result += "[Enter] add [Esc] cancel\n"
+	} else {
+		result += "\n[a]dd [d]elete [Esc] back\n"
+	}
+
+	if time.Now().Before(m.statusTimeout) {
+		result += fmt.Sprintf("\n%s\n", m.statusMessage)
+	}
+	return result
+}
+
+// formatBytes returns a byte count in human readable units
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -1,16 +1,23 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/SimonLariz/beacon/internal/model"
 	"github.com/SimonLariz/beacon/internal/ssh"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 )
 
+// stderrStyle highlights stderr in command output
+var stderrStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+
 // ViewMode represents the current view mode
 type ViewMode int
 
@@ -19,69 +26,13 @@ const (
 	ModeAddForm
 	ModeCommandInput
 	ModeCommandExecuting
+	ModeForwards
+	ModeFiles
+	ModeMarkInput
+	ModeBroadcast
+	ModeCompare
 )
 
-// AddConnectionForm holds the input fields for adding a new connection
-type AddConnectionForm struct {
-	fields []string
-	values map[string]string
-	active int
-}
-
-// NewAddConnectionForm creates a new AddConnectionForm
-func NewAddConnectionForm() *AddConnectionForm {
-	return &AddConnectionForm{
-		fields: []string{"alias", "host", "user", "port", "key_path"},
-		values: map[string]string{
-			"alias":    "",
-			"host":     "",
-			"user":     "",
-			"port":     "22",
-			"key_path": "",
-		},
-		active: 0,
-	}
-}
-
-// GetActiveField returns the name of the currently active field
-func (f *AddConnectionForm) GetActiveField() string {
-	if f.active < len(f.fields) {
-		return f.fields[f.active]
-	}
-	return ""
-}
-
-// NextField moves to the next field
-func (f *AddConnectionForm) NextField() {
-	f.active = (f.active + 1) % len(f.fields)
-}
-
-// PrevField moves to the previous field
-func (f *AddConnectionForm) PrevField() {
-	f.active--
-	if f.active < 0 {
-		f.active = len(f.fields) - 1
-	}
-}
-
-// AddChar adds a character to the active field
-func (f *AddConnectionForm) AddChar(ch string) {
-	f.values[f.GetActiveField()] += ch
-}
-
-// RemoveChar removes the last character from the active field
-func (f *AddConnectionForm) RemoveChar() {
-	field := f.GetActiveField()
-	if len(f.values[field]) > 0 {
-		f.values[field] = f.values[field][:len(f.values[field])-1]
-	}
-}
-
-// IsValid checks if all required fields are filled
-func (f *AddConnectionForm) IsValid() bool {
-	return f.values["alias"] != "" && f.values["host"] != "" && f.values["user"] != ""
-}
-
 // TUIModel represents the state of the TUI application
 type TUIModel struct {
 	AppState      *model.AppState
@@ -91,25 +42,71 @@ type TUIModel struct {
 	form          *AddConnectionForm
 	commandInput  string
 	historyIndex  int
+	search        *historySearch // Active Ctrl+R history search, nil otherwise
+	saveSeq       int            // Latest debounced config save, earlier ones are skipped
+	unsaved       bool           // Config changes waiting for a debounced save
 	statusMessage string
 	statusTimeout time.Time
+
+	events         chan tea.Msg       // Messages sent by background goroutines
+	hostKeyPrompts []hostKeyPromptMsg // Unknown host keys waiting for an answer
+	secretPrompts  []secretPromptMsg  // Password/challenge prompts waiting for an answer
+	secretInput    string             // Masked input for the active secret prompt
+	secretRemember bool               // Whether to remember the entered password
+
+	cancels   map[*model.CommandExecution]context.CancelFunc // Stops running commands
+	executing *model.CommandExecution                        // Command ModeCommandExecuting waits on
+
+	forwardIndex  int    // Selected forward in the forwards panel
+	forwardAdding bool   // Whether a new forward is being typed
+	forwardInput  string // Spec of the forward being added
+
+	files *fileBrowser // Open file browser, nil when closed
+
+	reconnectPrompt *model.ConnectionState // Edited live connection offered a reconnect, nil otherwise
+
+	markInput      string           // Pattern of connections to mark
+	broadcast      *model.Broadcast // Last broadcast command, shown in the results grid
+	broadcastIndex int              // Selected host in the results grid
+
+	comparison        *model.OutputComparison // Output groups shown in the compare view
+	compareGroup      int                     // Group diffed against the majority
+	compareScroll     int
+	compareLines      []string // Rendered diff of the selected group, kept between frames
+	compareSideBySide bool     // Side-by-side instead of unified diff
+	compareReturn     ViewMode // Mode the compare view was opened from
 }
 
-func NewTUIModel() *TUIModel {
+// NewTUIModel creates the TUI model from the saved configuration
+// A config from a newer beacon is an error, saving over it would lose its settings
+func NewTUIModel() (*TUIModel, error) {
 	appState := model.NewAppState()
 
 	// Load existing configuration
 	config, err := model.LoadConfig()
+	if errors.Is(err, model.ErrConfigTooNew) {
+		return nil, err
+	}
 	if err != nil {
 		log.Printf("Warning: Failed to load config: %v", err)
-	} else if config != nil && len(config.Connections) > 0 {
+	} else if config != nil {
 		appState.Config = config
+		retention := config.HistoryRetention()
 		for _, conn := range config.Connections {
+			// Show what ran in earlier sessions
+			executions := make([]*model.CommandExecution, 0)
+			if retention.Limit > 0 {
+				logged, err := model.LoadHistory(conn.Alias, retention)
+				if err != nil {
+					log.Printf("Warning: failed to load history of %s: %v", conn.Alias, err)
+				}
+				executions = append(executions, logged...)
+			}
 			appState.Connections = append(appState.Connections, &model.ConnectionState{
 				Connection: conn,
 				Status:     model.StatusDisconnected,
 				Output:     make([]string, 0),
-				Executions: make([]*model.CommandExecution, 0),
+				Executions: executions,
 			})
 		}
 		// Load command history
@@ -121,102 +118,349 @@ func NewTUIModel() *TUIModel {
 		mode:         ModeNormal,
 		form:         NewAddConnectionForm(),
 		historyIndex: -1,
-	}
+		events:       make(chan tea.Msg),
+		cancels:      make(map[*model.CommandExecution]context.CancelFunc),
+	}, nil
 }
 
 // Init initializes the model
 func (m *TUIModel) Init() tea.Cmd {
-	return nil
+	return listenForEvents(m.events)
 }
 
 // Update handles user input
 func (m *TUIModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case hostKeyPromptMsg:
+		m.hostKeyPrompts = append(m.hostKeyPrompts, msg)
+		return m, listenForEvents(m.events)
+	case secretPromptMsg:
+		m.secretPrompts = append(m.secretPrompts, msg)
+		return m, listenForEvents(m.events)
 	case connectResultMsg:
 		// Handle connection result
+		cs := m.connectState(msg)
+		if cs == nil {
+			// The connection was deleted or connected again meanwhile
+			if msg.client != nil {
+				_ = msg.client.Disconnect()
+			}
+			return m, nil
+		}
+		if msg.err == nil {
+			if cs.Status == model.StatusReconnecting {
+				m.setStatus(fmt.Sprintf("Reconnected to %s", cs.Connection.Alias), 3*time.Second)
+			}
+			cs.Client = msg.client
+			cs.Status = model.StatusConnected
+			cs.LastError = nil
+			cs.LastActive = time.Now()
+			cs.ReconnectAttempts = 0
+			m.startKeepalive(cs)
+			m.startForwards(cs)
+			if msg.password != "" {
+				cs.Connection.Password = msg.password
+				if err := model.SaveConfig(m.AppState.Config); err != nil {
+					log.Printf("Warning: failed to save config: %v", err)
+				}
+			}
+		} else if cs.Status == model.StatusReconnecting {
+			return m, m.reconnectFailed(cs, msg.err)
+		} else {
+			cs.Status = model.StatusError
+			cs.LastError = msg.err
+		}
+	case connectionLostMsg:
+		for _, cs := range m.AppState.Connections {
+			if cs.Client == msg.client {
+				return m, tea.Batch(listenForEvents(m.events), m.connectionLost(cs, msg.err))
+			}
+		}
+		return m, listenForEvents(m.events)
+	case reconnectMsg:
+		for _, cs := range m.AppState.Connections {
+			if cs == msg.state && cs.Status == model.StatusReconnecting {
+				return m, m.connectToServer(cs)
+			}
+		}
+	case saveConfigMsg:
+		if msg.seq == m.saveSeq {
+			m.saveConfig()
+		}
+	case forwardTickMsg:
+		if m.mode == ModeForwards {
+			return m, forwardTick()
+		}
+	case sftpOpenedMsg:
+		return m, m.sftpOpened(msg)
+	case paneLoadedMsg:
+		m.paneLoaded(msg)
+	case transferDoneMsg:
+		return m, m.transferDone(msg)
+	case editFetchedMsg:
+		return m, m.editFetched(msg)
+	case editorExitMsg:
+		return m, m.editorExited(msg)
+	case editCheckedMsg:
+		return m, m.editChecked(msg)
+	case editSavedMsg:
+		return m, m.editSaved(msg)
+	case broadcastTickMsg:
+		if m.broadcast != nil && m.broadcast.Finished() < len(m.broadcast.Hosts) {
+			return m, broadcastTick()
+		}
+	case transferTickMsg:
+		if m.files != nil && len(m.files.transfers) > 0 {
+			return m, transferTick()
+		}
+	case shellExitMsg:
 		if msg.index >= 0 && msg.index < len(m.AppState.Connections) {
 			cs := m.AppState.Connections[msg.index]
-			if msg.success {
-				cs.Status = model.StatusConnected
-				cs.LastError = nil
-				cs.LastActive = time.Now()
-			} else {
-				cs.Status = model.StatusError
-				cs.LastError = msg.err
+			cs.LastActive = time.Now()
+			switch {
+			case msg.err != nil:
+				m.setStatus(fmt.Sprintf("Shell error: %v", msg.err), 5*time.Second)
+			case msg.detached:
+				m.setStatus(fmt.Sprintf("Detached from %s", cs.Connection.Alias), 3*time.Second)
+			default:
+				m.setStatus(fmt.Sprintf("Shell on %s closed", cs.Connection.Alias), 3*time.Second)
 			}
 		}
+	case importResultMsg:
+		if msg.err != nil {
+			m.setStatus(fmt.Sprintf("Import failed: %v", msg.err), 5*time.Second)
+			return m, nil
+		}
+		result := m.AppState.ImportConnections(msg.connections)
+		if len(result.Added)+len(result.Merged) > 0 {
+			if err := model.SaveConfig(m.AppState.Config); err != nil {
+				log.Printf("Warning: failed to save config: %v", err)
+			}
+		}
+		m.setStatus(fmt.Sprintf("Imported from ~/.ssh/config: %s", result), 5*time.Second)
+	case commandOutputMsg:
+		msg.execution.AppendOutput(msg.chunk.Stderr, string(msg.chunk.Data))
+		return m, waitForCommandUpdate(msg.updates)
 	case commandResultMsg:
 		// Handle command result
-		if msg.index >= 0 && msg.index < len(m.AppState.Connections) {
-			cs := m.AppState.Connections[msg.index]
+		var cmd tea.Cmd
+		if cs := m.resultState(msg); cs != nil {
 			if msg.err != nil {
-				m.setStatus(fmt.Sprintf("Error: %v", msg.err), 5*time.Second)
-				cs.Status = model.StatusError
-				cs.LastError = msg.err
+				if msg.broadcast != nil {
+					msg.broadcast.Host(msg.execution).Err = msg.err
+				} else {
+					m.setStatus(fmt.Sprintf("Error: %v", msg.err), 5*time.Second)
+				}
+				// Only a dead transport is worth reconnecting, a refused session
+				// leaves the connection usable. The keepalive loop may have
+				// noticed the dead link first, or a reconnect replaced the client
+				if cs.Status == model.StatusConnected && msg.client != nil && cs.Client == msg.client &&
+					ssh.IsConnectionError(msg.err) {
+					_ = cs.Client.Disconnect()
+					cmd = m.connectionLost(cs, msg.err)
+				}
 			} else {
+				msg.execution.Finish(msg.result.ExitCode, msg.result.Duration, msg.result.Error)
 				cs.Executions = append(cs.Executions, msg.execution)
+				if m.AppState.Config.HistoryRetention().Limit > 0 {
+					if err := model.AppendHistory(cs.Connection.Alias, msg.execution); err != nil {
+						log.Printf("Warning: failed to log execution: %v", err)
+					}
+				}
 				exitMsg := "completed"
-				if msg.execution.ExitCode != 0 {
+				switch {
+				case msg.execution.Outcome == model.OutcomeTimedOut:
+					exitMsg = fmt.Sprintf("timed out after %s", msg.execution.Timeout)
+				case msg.execution.Outcome == model.OutcomeCancelled:
+					exitMsg = "cancelled"
+				case msg.execution.ExitCode != 0:
 					exitMsg = fmt.Sprintf("exit %d", msg.execution.ExitCode)
 				}
-				m.setStatus(fmt.Sprintf("Command %s", exitMsg), 3*time.Second)
+				if msg.broadcast == nil {
+					m.setStatus(fmt.Sprintf("Command %s", exitMsg), 3*time.Second)
+				}
+			}
+			if cs.CurrentExec == msg.execution {
+				cs.CurrentExec = nil
+			}
+		} else if msg.execution != nil {
+			// The connection was deleted meanwhile, only settle a broadcast waiting on it
+			if msg.err != nil && msg.broadcast != nil {
+				msg.broadcast.Host(msg.execution).Err = msg.err
+			} else if msg.result != nil {
+				msg.execution.Finish(msg.result.ExitCode, msg.result.Duration, msg.result.Error)
 			}
-			cs.CurrentExec = nil
 		}
-		m.mode = ModeNormal
+		if msg.execution != nil {
+			if cancel, ok := m.cancels[msg.execution]; ok {
+				cancel()
+				delete(m.cancels, msg.execution)
+			}
+		}
+		if msg.broadcast != nil {
+			m.broadcastProgress(msg.broadcast)
+		}
+		// Only the command the view waits on ends the wait, not a broadcast
+		// host or a command still running on another connection
+		if m.mode == ModeCommandExecuting && msg.execution == m.executing {
+			m.mode = ModeNormal
+			m.executing = nil
+		}
+		return m, cmd
 	case tea.KeyMsg:
+		// Prompts take over the keyboard until answered
+		if len(m.hostKeyPrompts) > 0 {
+			return m.handleHostKeyPrompt(msg)
+		}
+		if len(m.secretPrompts) > 0 {
+			return m.handleSecretPrompt(msg)
+		}
+		if m.reconnectPrompt != nil {
+			return m.handleReconnectPrompt(msg)
+		}
 		if m.mode == ModeCommandInput {
 			return m.handleCommandInput(msg)
 		}
+		if m.mode == ModeForwards {
+			return m.handleForwardsKey(msg)
+		}
+		if m.mode == ModeFiles {
+			return m.handleFilesKey(msg)
+		}
+		if m.mode == ModeMarkInput {
+			return m.handleMarkInput(msg)
+		}
+		if m.mode == ModeBroadcast {
+			return m.handleBroadcastKey(msg)
+		}
+		if m.mode == ModeCompare {
+			return m.handleCompareKey(msg)
+		}
+		if m.mode == ModeCommandExecuting {
+			return m.handleExecutingKey(msg)
+		}
 		return m.handleKeyPress(msg)
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
+		if m.mode == ModeCompare && m.compareSideBySide {
+			// Columns follow the terminal width
+			m.refreshCompare()
+		}
+	default:
+		// Clipboard pastes come back as a message for the form's input
+		if m.mode == ModeAddForm {
+			return m, m.form.Update(msg, m.AppState.Config)
+		}
 	}
 	return m, nil
 }
 
 // View renders the TUI
 func (m *TUIModel) View() string {
+	if len(m.hostKeyPrompts) > 0 {
+		return m.renderHostKeyPrompt()
+	}
+	if len(m.secretPrompts) > 0 {
+		return m.renderSecretPrompt()
+	}
+	if m.reconnectPrompt != nil {
+		return m.renderReconnectPrompt()
+	}
+
 	if m.mode == ModeAddForm {
 		return m.renderAddForm()
 	}
 
+	if m.mode == ModeForwards {
+		return m.renderForwards()
+	}
+
+	if m.mode == ModeFiles {
+		return m.renderFiles()
+	}
+
+	if m.mode == ModeBroadcast {
+		return m.renderBroadcast()
+	}
+
+	if m.mode == ModeCompare {
+		return m.renderCompare()
+	}
+
 	if len(m.AppState.Connections) == 0 {
-		return "No connections. Press 'a' to add one, or 'q' to quit.\n"
+		return "No connections. Press 'a' to add one, 'i' to import ~/.ssh/config, or 'q' to quit.\n"
 	}
 
 	var result string
 	result += "=== BEACON - SSH Session Manager ===\n\n"
 	result += "Connections:\n"
 
-	for i, cs := range m.AppState.Connections {
+	for _, row := range m.AppState.TreeRows() {
+		indent := strings.Repeat("  ", row.Depth)
+		if row.Index < 0 {
+			marker := "  "
+			if row.Group == m.AppState.SelectedGroup {
+				marker = "> "
+			}
+			arrow := "▾"
+			if row.Collapsed {
+				arrow = "▸"
+			}
+			result += fmt.Sprintf("%s%s%s %s/ (%s)\n", marker, indent, arrow, row.Name, row.Counts)
+			continue
+		}
+
+		i, cs := row.Index, m.AppState.Connections[row.Index]
 		marker := "  "
-		if i == m.AppState.SelectedIndex {
+		if m.AppState.SelectedGroup == "" && i == m.AppState.SelectedIndex {
 			marker = "> "
 		}
+		mark := " "
+		if cs.Marked {
+			mark = "*"
+		}
 
 		// Color-code status (use lipgloss later)
 		status := cs.StatusString()
 
-		result += fmt.Sprintf("%s[%d] %s @ %s:%d (user: %s) - %s\n",
+		user := cs.Connection.User
+		if user == "" {
+			user = "from ssh config"
+		}
+
+		tags := ""
+		for _, tag := range cs.Connection.Tags {
+			tags += " #" + tag
+		}
+
+		result += fmt.Sprintf("%s%s%s[%d] %s @ %s (user: %s) - %s%s\n",
 			marker,
+			indent,
+			mark,
 			i,
 			cs.Connection.Alias,
-			cs.Connection.Host,
-			cs.Connection.Port,
-			cs.Connection.User,
+			cs.Connection.Address(),
+			user,
 			status,
+			tags,
 		)
 
+		// Show the bastions the connection goes through
+		if hopPath := m.AppState.Config.HopPath(cs.Connection); hopPath != "" {
+			result += fmt.Sprintf("%s     via %s\n", indent, hopPath)
+		}
+
 		// Show error if present
 		if cs.LastError != nil {
-			result += fmt.Sprintf("     Error: %v\n", cs.LastError)
+			result += fmt.Sprintf("%s     Error: %v\n", indent, cs.LastError)
 		}
 		result += "\n"
 	}
 
-	result += "\n[a]dd [d]elete [c]onnect [:]command [q]uit\n"
+	result += "\n[a]dd [e]dit [i]mport [d]elete [c]onnect [:]command [s]hell [f]orwards [b]rowse files [q]uit\n"
+	result += "[Space] mark [m]ark matching [M] clear marks [B]roadcast results [D]iff last command across hosts\n"
+	result += "[Enter/←→] collapse/expand group\n"
 
 	// Render command output if connection is selected
 	if m.AppState.GetSelected() != nil {
@@ -227,6 +471,9 @@ func (m *TUIModel) View() string {
 	if m.mode == ModeCommandInput {
 		result += m.renderCommandInput()
 	}
+	if m.mode == ModeMarkInput {
+		result += m.renderMarkInput()
+	}
 
 	// Status message
 	if time.Now().Before(m.statusTimeout) {
@@ -236,88 +483,65 @@ func (m *TUIModel) View() string {
 	return result
 }
 
-// renderAddForm renders the add connection form
-func (m *TUIModel) renderAddForm() string {
-	var result string
-	result += "=== ADD NEW CONNECTION ===\n\n"
-
-	// Render each field
-	fields := []string{"alias", "host", "user", "port", "key_path"}
-	labels := []string{"Alias (nickname)", "Host (IP or hostname)", "User (SSH username)", "Port (default 22)", "Key Path (optional)"}
-
-	for i, field := range fields {
-		prefix := "  "
-		if i == m.form.active {
-			prefix = "> " // Highlight active field
-		}
-		result += fmt.Sprintf("%s%s: %s\n", prefix, labels[i], m.form.values[field])
+// handleExecutingKey handles keys while waiting on a command
+// Only Ctrl+C does anything, it interrupts the command shown
+func (m *TUIModel) handleExecutingKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if msg.String() != "ctrl+c" || m.executing == nil {
+		return m, nil
 	}
-
-	result += "\n[Tab]next [Shift+Tab]prev [Enter]save [Esc]cancel\n"
-	return result
+	if cancel, ok := m.cancels[m.executing]; ok {
+		cancel()
+		m.setStatus(fmt.Sprintf("Interrupting %q...", m.executing.Command), 10*time.Second)
+	}
+	return m, nil
 }
 
 func (m *TUIModel) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	// If in add mode, handle form input
 	if m.mode == ModeAddForm {
-		switch msg.String() {
-		case "esc":
-			m.mode = ModeNormal
-			m.form = NewAddConnectionForm()
-		case "tab":
-			m.form.NextField()
-		case "shift+tab":
-			m.form.PrevField()
-		case "enter":
-			if m.form.IsValid() {
-				// Create and add the connection
-				port := 22
-				if _, err := fmt.Sscanf(m.form.values["port"], "%d", &port); err != nil {
-					// If invalid port, use default
-					port = 22
-				}
-
-				conn := model.NewConnection(
-					m.form.values["alias"],
-					m.form.values["host"],
-					m.form.values["user"],
-					port,
-				)
-				conn.KeyPath = m.form.values["key_path"]
-				m.AppState.AddConnection(conn)
-				if err := model.SaveConfig(m.AppState.Config); err != nil {
-					log.Printf("Warning: failed to save config: %v", err)
-				}
-
-				m.mode = ModeNormal
-				m.form = NewAddConnectionForm()
-			}
-		case "backspace":
-			m.form.RemoveChar()
-		default:
-			// Add character to active field
-			if len(msg.String()) == 1 {
-				m.form.AddChar(msg.String())
-			}
-		}
-		return m, nil
+		return m.handleFormKey(msg)
 	}
 
 	// Normal mode key handling
 	switch msg.String() {
-	case "q", "ctrl+c":
+	case "ctrl+c":
+		// Interrupt the selected server's running command before quitting
+		if selected := m.AppState.GetSelected(); selected != nil && selected.CurrentExec != nil {
+			if cancel, ok := m.cancels[selected.CurrentExec]; ok {
+				cancel()
+				m.setStatus(fmt.Sprintf("Interrupting %q...", selected.CurrentExec.Command), 10*time.Second)
+				return m, nil
+			}
+		}
+		return m, tea.Quit
+	case "q":
 		return m, tea.Quit
 	case "up":
 		m.AppState.SelectPrevious()
 	case "down":
 		m.AppState.SelectNext()
+	case "enter":
+		m.AppState.ToggleCollapsed()
+	case "left":
+		m.AppState.CollapseSelected()
+	case "right":
+		m.AppState.ExpandSelected()
 	case "a":
 		m.mode = ModeAddForm
 		m.form = NewAddConnectionForm()
+	case "e":
+		m.openEditForm()
+	case "i":
+		return m, m.importSSHConfig()
 	case "d":
-		if len(m.AppState.Connections) > 0 {
+		if selected := m.AppState.GetSelected(); selected != nil {
+			alias := selected.Connection.Alias
+			// Nothing would be left to stop its listeners and goroutines
+			m.disconnect(selected)
 			if err := m.AppState.DeleteConnection(m.AppState.SelectedIndex); err != nil {
 				log.Printf("Warning: failed to delete connection: %v", err)
+			} else if err := model.DeleteHistory(alias); err != nil {
+				log.Printf("Warning: %v", err)
 			}
 			if err := model.SaveConfig(m.AppState.Config); err != nil {
 				log.Printf("Warning: failed to save config: %v", err)
@@ -327,23 +551,54 @@ func (m *TUIModel) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		if m.mode == ModeNormal && m.AppState.GetSelected() != nil {
 			cs := m.AppState.GetSelected()
 			// Don't connect if already connecting/connected
-			if cs.Status == model.StatusConnecting || cs.Status == model.StatusConnected {
+			if cs.Status == model.StatusConnecting || cs.Status == model.StatusConnected ||
+				cs.Status == model.StatusReconnecting {
 				return m, nil
 			}
 			// Mark as connecting
 			cs.Status = model.StatusConnecting
 			// Start async connection
-			return m, m.connectToSelectedServer()
+			return m, m.connectToServer(cs)
 		}
 	case ":":
 		selected := m.AppState.GetSelected()
-		if selected != nil && selected.Status == model.StatusConnected {
+		if len(m.AppState.MarkedConnections()) > 0 {
+			// Marked connections get the command instead of the selected one
+			m.mode = ModeCommandInput
+			m.commandInput = ""
+			m.historyIndex = -1
+		} else if selected != nil && selected.CurrentExec != nil {
+			m.setStatus("A command is already running, Ctrl+C to interrupt it", 2*time.Second)
+		} else if selected != nil && selected.Status == model.StatusConnected {
 			m.mode = ModeCommandInput
 			m.commandInput = ""
 			m.historyIndex = -1
 		} else {
 			m.setStatus("No connected server selected", 2*time.Second)
 		}
+	case "s":
+		return m, m.openShell()
+	case "f":
+		return m, m.openForwards()
+	case "b":
+		return m, m.openFiles()
+	case " ":
+		if m.AppState.SelectedGroup != "" {
+			m.AppState.ToggleGroupMarked(m.AppState.SelectedGroup)
+		} else {
+			m.AppState.ToggleMarked(m.AppState.SelectedIndex)
+		}
+	case "m":
+		m.mode = ModeMarkInput
+		m.markInput = ""
+	case "M":
+		m.AppState.ClearMarked()
+	case "B":
+		if m.broadcast != nil {
+			m.mode = ModeBroadcast
+		}
+	case "D":
+		m.compareSelected()
 	case "pgup":
 		m.AppState.ScrollOutputUp(10)
 	case "pgdown":
@@ -360,7 +615,15 @@ func (m *TUIModel) setStatus(msg string, duration time.Duration) {
 
 // handleCommandInput processes key input when in command input mode
 func (m *TUIModel) handleCommandInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.search != nil {
+		return m.handleSearchKey(msg)
+	}
+
 	switch msg.String() {
+	case "ctrl+r":
+		m.startSearch()
+		return m, nil
+
 	case "esc":
 		m.mode = ModeNormal
 		m.commandInput = ""
@@ -374,24 +637,30 @@ func (m *TUIModel) handleCommandInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 
 		cmd := m.commandInput
-		m.AppState.AddToHistory(cmd)
+		save := m.recordCommand(cmd)
 		m.commandInput = ""
 		m.historyIndex = -1
+		if len(m.AppState.MarkedConnections()) > 0 {
+			return m, tea.Batch(save, m.executeBroadcast(cmd))
+		}
 		m.mode = ModeCommandExecuting
 
-		return m, m.executeCommand(cmd)
+		return m, tea.Batch(save, m.executeCommand(cmd))
 
 	case "up":
-		if m.historyIndex < m.AppState.HistorySize()-1 {
+		// Commands of the selected connection, or of every connection for a broadcast
+		history := m.inputHistory()
+		if m.historyIndex < len(history)-1 {
 			m.historyIndex++
-			m.commandInput = m.AppState.GetHistoryItem(m.historyIndex)
+			m.commandInput = history[len(history)-1-m.historyIndex]
 		}
 		return m, nil
 
 	case "down":
+		history := m.inputHistory()
 		if m.historyIndex > 0 {
 			m.historyIndex--
-			m.commandInput = m.AppState.GetHistoryItem(m.historyIndex)
+			m.commandInput = history[len(history)-1-m.historyIndex]
 		} else if m.historyIndex == 0 {
 			m.historyIndex = -1
 			m.commandInput = ""
@@ -416,47 +685,109 @@ func (m *TUIModel) handleCommandInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 func (m *TUIModel) executeCommand(cmd string) tea.Cmd {
 	selected := m.AppState.GetSelected()
 	if selected == nil || selected.Client == nil {
-		return func() tea.Msg {
-			return commandResultMsg{
-				index: m.AppState.SelectedIndex,
-				err:   fmt.Errorf("no active connection"),
-			}
-		}
+		m.mode = ModeNormal
+		m.setStatus("Error: no active connection", 5*time.Second)
+		return nil
 	}
 
 	index := m.AppState.SelectedIndex
 
-	// Mark command as executing
-	selected.CurrentExec = &model.CommandExecution{
-		Command:   cmd,
-		Timestamp: time.Now(),
-		Completed: false,
+	// A "@<duration> " prefix overrides the configured timeout
+	cmd, timeout, err := model.ParseCommandTimeout(cmd)
+	if err != nil {
+		m.mode = ModeNormal
+		m.setStatus(fmt.Sprintf("Error: %v", err), 5*time.Second)
+		return nil
+	}
+	if timeout == 0 {
+		timeout = m.AppState.Config.CommandTimeoutFor(selected.Connection)
 	}
 
-	return func() tea.Msg {
-		result, err := selected.Client.ExecuteCommand(cmd)
+	execution := model.NewCommandExecution(cmd)
+	execution.Timeout = timeout
+	m.executing = execution
+	return m.startExecution(index, selected, execution, nil, nil)
+}
 
-		if err != nil {
-			return commandResultMsg{
-				index: index,
-				err:   err,
+// startExecution runs an execution on a connection, streaming its output
+// sem, when set, limits how many executions run at once, the timeout only
+// starts counting once a slot is free
+// broadcast is the broadcast the execution belongs to, nil for a single command
+func (m *TUIModel) startExecution(index int, cs *model.ConnectionState, execution *model.CommandExecution,
+	sem chan struct{}, broadcast *model.Broadcast) tea.Cmd {
+	client := cs.Client
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Mark command as executing, output is appended as it streams in
+	cs.CurrentExec = execution
+	m.cancels[execution] = cancel
+
+	// Output chunks and the final result share one channel so the result
+	// is only handled after every chunk before it
+	updates := make(chan tea.Msg, 64)
+	run := func() tea.Msg {
+		if sem != nil {
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				// Cancelled while waiting for a slot
+				result := &ssh.CommandResult{ExitCode: -1, Error: ctx.Err()}
+				updates <- commandResultMsg{index: index, state: cs, client: client, execution: execution,
+					result: result, broadcast: broadcast}
+				return nil
 			}
 		}
+		if broadcast != nil {
+			broadcast.Host(execution).Start()
+		}
 
-		execution := &model.CommandExecution{
-			Command:   cmd,
-			Timestamp: time.Now(),
-			ExitCode:  result.ExitCode,
-			Stdout:    result.Stdout,
-			Stderr:    result.Stderr,
-			Duration:  result.Duration,
-			Completed: true,
+		runCtx := ctx
+		if execution.Timeout > 0 {
+			var stop context.CancelFunc
+			runCtx, stop = context.WithTimeout(ctx, execution.Timeout)
+			defer stop()
 		}
 
-		return commandResultMsg{
-			index:     index,
-			execution: execution,
+		result, err := client.ExecuteCommandStream(runCtx, execution.Command, func(chunk ssh.OutputChunk) {
+			updates <- commandOutputMsg{index: index, execution: execution, chunk: chunk, updates: updates}
+		})
+		if err != nil {
+			updates <- commandResultMsg{index: index, state: cs, client: client, execution: execution,
+				err: err, broadcast: broadcast}
+			return nil
+		}
+
+		updates <- commandResultMsg{index: index, state: cs, client: client, execution: execution,
+			result: result, broadcast: broadcast}
+		return nil
+	}
+
+	return tea.Batch(run, waitForCommandUpdate(updates))
+}
+
+// resultState returns the connection a command result belongs to
+// It is nil when the connection was deleted while the command ran
+func (m *TUIModel) resultState(msg commandResultMsg) *model.ConnectionState {
+	if msg.state == nil {
+		if msg.index >= 0 && msg.index < len(m.AppState.Connections) {
+			return m.AppState.Connections[msg.index]
 		}
+		return nil
+	}
+	// Deleting a connection shifts the indexes of the ones after it
+	for _, cs := range m.AppState.Connections {
+		if cs == msg.state {
+			return cs
+		}
+	}
+	return nil
+}
+
+// waitForCommandUpdate waits for the next output chunk or the result of a running command
+func waitForCommandUpdate(updates <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return <-updates
 	}
 }
 
@@ -470,10 +801,29 @@ func (m *TUIModel) renderCommandOutput() string {
 	var result string
 	result += fmt.Sprintf("\n━━━ Command Output (%s) ━━━\n", selected.Connection.Alias)
 
-	// Show currently executing command
+	// Viewport height for the output
+	outputHeight := m.height - 15
+	if outputHeight < 5 {
+		outputHeight = 5
+	}
+
+	// Show currently executing command with the tail of its output so far
 	if selected.CurrentExec != nil {
-		result += fmt.Sprintf("\n$ %s\n", selected.CurrentExec.Command)
-		result += "[Executing...]\n"
+		exec := selected.CurrentExec
+		limit := ""
+		if exec.Timeout > 0 {
+			limit = fmt.Sprintf(", timeout %s", exec.Timeout)
+		}
+		result += fmt.Sprintf("\n$ %s  [Executing%s... Ctrl+C to interrupt]\n", exec.Command, limit)
+
+		liveHeight := outputHeight / 2
+		if liveHeight < 3 {
+			liveHeight = 3
+		}
+		lines := executionLines(exec, liveHeight)
+		for _, line := range lines {
+			result += line + "\n"
+		}
 	}
 
 	// Show execution history
@@ -492,28 +842,18 @@ func (m *TUIModel) renderCommandOutput() string {
 		allLines = append(allLines, "")
 		allLines = append(allLines, fmt.Sprintf("$ %s  [%s]", exec.Command, timestamp))
 
-		if exec.Stdout != "" {
-			lines := strings.Split(strings.TrimRight(exec.Stdout, "\n"), "\n")
-			allLines = append(allLines, lines...)
-		}
-
-		if exec.Stderr != "" {
-			allLines = append(allLines, "--- stderr ---")
-			lines := strings.Split(strings.TrimRight(exec.Stderr, "\n"), "\n")
-			allLines = append(allLines, lines...)
-		}
+		allLines = append(allLines, executionLines(exec, 0)...)
 
-		if exec.ExitCode != 0 {
+		switch {
+		case exec.Outcome == model.OutcomeTimedOut:
+			allLines = append(allLines, fmt.Sprintf("[Timed out after %s]", exec.Timeout))
+		case exec.Outcome == model.OutcomeCancelled:
+			allLines = append(allLines, fmt.Sprintf("[Cancelled after %s]", exec.Duration.Round(time.Millisecond)))
+		case exec.ExitCode != 0:
 			allLines = append(allLines, fmt.Sprintf("[Exit code: %d]", exec.ExitCode))
 		}
 	}
 
-	// Apply scrolling and viewport
-	outputHeight := m.height - 15
-	if outputHeight < 5 {
-		outputHeight = 5
-	}
-
 	totalLines := len(allLines)
 	startLine := m.AppState.OutputScrollOffset
 	endLine := startLine + outputHeight
@@ -540,6 +880,46 @@ func (m *TUIModel) renderCommandOutput() string {
 	return result
 }
 
+// executionLines returns the output of an execution as display lines
+// Streamed output keeps stdout/stderr interleaving, with stderr highlighted
+// A positive tail only renders that many of the last lines
+func executionLines(exec *model.CommandExecution, tail int) []string {
+	var lines []string
+	if exec.Output == nil {
+		if exec.Stdout != "" {
+			lines = append(lines, strings.Split(strings.TrimRight(exec.Stdout, "\n"), "\n")...)
+		}
+		if exec.Stderr != "" {
+			lines = append(lines, "--- stderr ---")
+			lines = append(lines, strings.Split(strings.TrimRight(exec.Stderr, "\n"), "\n")...)
+		}
+		if tail > 0 && len(lines) > tail {
+			lines = lines[len(lines)-tail:]
+		}
+		return lines
+	}
+
+	if dropped := exec.Output.Dropped(); dropped > 0 {
+		lines = append(lines, fmt.Sprintf("[... %d bytes of earlier output dropped ...]", dropped))
+	}
+	output := exec.Output.Lines()
+	if tail > 0 && len(output) >= tail {
+		lines, output = nil, output[len(output)-tail:]
+	}
+	for _, fragments := range output {
+		var line string
+		for _, fragment := range fragments {
+			if fragment.Stderr {
+				line += stderrStyle.Render(fragment.Text)
+			} else {
+				line += fragment.Text
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
 // renderCommandInput renders the command input bar
 func (m *TUIModel) renderCommandInput() string {
 	if m.mode != ModeCommandInput {
@@ -548,49 +928,117 @@ func (m *TUIModel) renderCommandInput() string {
 
 	var result string
 	result += "\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n"
+	if marked := len(m.AppState.MarkedConnections()); marked > 0 {
+		result += fmt.Sprintf("Broadcast to %d marked connection(s)\n", marked)
+	}
+	if m.search != nil {
+		result += m.renderSearch()
+		return result
+	}
 	result += fmt.Sprintf(":%s█\n", m.commandInput)
-	result += "[↑↓ history] [Enter] execute [Esc] cancel\n"
+	result += "[↑↓ history] [Ctrl+R] search [@30s cmd] timeout [Enter] execute [Esc] cancel\n"
 	return result
 }
 
 type connectResultMsg struct {
-	index   int // which connection
-	success bool
-	err     error
+	state    *model.ConnectionState // Connection that was connected
+	client   *ssh.SSHClientWrapper  // The new client, nil if connecting failed
+	err      error
+	password string // Password to remember, if the user asked for it
 }
 
 type commandResultMsg struct {
 	index     int
+	state     *model.ConnectionState // Connection the command ran on, nil if there was none
+	client    *ssh.SSHClientWrapper  // Client the command ran on
 	execution *model.CommandExecution
+	result    *ssh.CommandResult // Exit code and duration, output was already streamed
 	err       error
+	broadcast *model.Broadcast // Broadcast the command is part of, nil for a single command
 }
 
-// connectToSelectedServer initiates SSH connection asynchronously
+// commandOutputMsg carries output of a running command as it arrives
+type commandOutputMsg struct {
+	index     int
+	execution *model.CommandExecution
+	chunk     ssh.OutputChunk
+	updates   <-chan tea.Msg // Where the next chunk or the result arrives
+}
+
+type importResultMsg struct {
+	connections []*model.Connection
+	err         error
+}
+
+// importSSHConfig reads ~/.ssh/config asynchronously
+func (m *TUIModel) importSSHConfig() tea.Cmd {
+	return func() tea.Msg {
+		connections, err := model.ConnectionsFromSSHConfig()
+		return importResultMsg{connections: connections, err: err}
+	}
+}
+
+// connectToServer initiates SSH connection asynchronously
 // Returns a bubbletea.Cmd that will send a message when done
-func (m *TUIModel) connectToSelectedServer() tea.Cmd {
-	// Get selected connection
-	selected := m.AppState.GetSelected()
-	if selected == nil {
-		return nil
+func (m *TUIModel) connectToServer(cs *model.ConnectionState) tea.Cmd {
+	conn := cs.Connection
+	opts := conn.ConnectOptions()
+	opts.ConfirmHostKey = m.hostKeyConfirmer(conn.Alias)
+
+	jumps, err := m.AppState.Config.ResolveJumps(conn)
+	if err != nil {
+		return func() tea.Msg {
+			return connectResultMsg{state: cs, err: err}
+		}
 	}
-	// Call ssh.Connect in a goroutine
+	opts.JumpHosts = jumps
+
+	var remembered string
+	opts.PromptSecret = m.secretPrompter(conn, &remembered)
+
+	// Call ssh.Connect in a goroutine, the client is stored by Update
 	return func() tea.Msg {
-		conn := selected.Connection
-		sshClient, err := ssh.Connect(conn.Host, conn.Port, conn.User, conn.KeyPath)
+		sshClient, err := ssh.Connect(conn.Host, conn.Port, conn.User, conn.KeyPath, opts)
 		if err != nil {
-			return connectResultMsg{index: m.AppState.SelectedIndex, success: false, err: err}
+			return connectResultMsg{state: cs, err: err}
 		}
-		// Store the SSH client in the connection state
-		selected.Client = sshClient
-		return connectResultMsg{index: m.AppState.SelectedIndex, success: true, err: nil}
+		return connectResultMsg{state: cs, client: sshClient, password: remembered}
 	}
 }
 
+// connectState returns the connection a connect result belongs to
+// It is nil when the connection was deleted, disconnected or connected by
+// another attempt while this one was in flight
+func (m *TUIModel) connectState(msg connectResultMsg) *model.ConnectionState {
+	for _, cs := range m.AppState.Connections {
+		if cs == msg.state {
+			if cs.Status != model.StatusConnecting && cs.Status != model.StatusReconnecting {
+				return nil
+			}
+			return cs
+		}
+	}
+	return nil
+}
+
 func main() {
-	model := NewTUIModel()
+	// Subcommands run without the TUI
+	if len(os.Args) > 1 {
+		os.Exit(runCLI(os.Args[1:]))
+	}
+
+	model, err := NewTUIModel()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "beacon: %v\n", err)
+		os.Exit(1)
+	}
 	p := tea.NewProgram(model)
 
 	if _, err := p.Run(); err != nil {
 		log.Fatalf("Error running program: %v", err)
 	}
+	// Commands run just before quitting are still waiting to be saved
+	if model.unsaved {
+		model.saveConfig()
+	}
 }
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/SimonLariz/beacon/internal/model"
+	"github.com/SimonLariz/beacon/internal/ssh"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// hostKeyPromptMsg asks the user to confirm an unknown host key
+// The connecting goroutine blocks on reply until the user answers
+type hostKeyPromptMsg struct {
+	alias   string
+	request ssh.HostKeyRequest
+	reply   chan ssh.HostKeyDecision
+}
+
+// secretPromptMsg asks the user for a password or keyboard-interactive answer
+// The connecting goroutine blocks on reply until the user answers
+type secretPromptMsg struct {
+	alias       string
+	prompt      ssh.SecretPrompt
+	canRemember bool // Whether the answer may be saved to connections.json
+	reply       chan secretAnswer
+}
+
+// secretAnswer is the user's reply to a secretPromptMsg
+type secretAnswer struct {
+	value    string
+	remember bool
+	err      error
+}
+
+// listenForEvents waits for the next message sent by a background goroutine
+// Update must call it again after handling each event to keep listening
+func listenForEvents(events chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return <-events
+	}
+}
+
+// hostKeyConfirmer returns a callback the ssh layer uses to ask about unknown hosts
+func (m *TUIModel) hostKeyConfirmer(alias string) ssh.HostKeyConfirmFunc {
+	events := m.events
+	return func(req ssh.HostKeyRequest) ssh.HostKeyDecision {
+		reply := make(chan ssh.HostKeyDecision, 1)
+		events <- hostKeyPromptMsg{alias: alias, request: req, reply: reply}
+		return <-reply
+	}
+}
+
+// secretPrompter returns a callback the ssh layer uses to ask for secrets
+// A password the user chose to remember is stored in *remembered
+func (m *TUIModel) secretPrompter(conn *model.Connection, remembered *string) ssh.SecretPromptFunc {
+	events := m.events
+	alias := conn.Alias
+	canRemember := conn.CanSavePassword()
+	return func(prompt ssh.SecretPrompt) (string, error) {
+		reply := make(chan secretAnswer, 1)
+		events <- secretPromptMsg{
+			alias:  alias,
+			prompt: prompt,
+			// Only the target's own password belongs in its connection entry
+			canRemember: canRemember && prompt.Kind == ssh.PromptPassword && !prompt.Jump,
+			reply:       reply,
+		}
+		answer := <-reply
+		if answer.err != nil {
+			return "", answer.err
+		}
+		if answer.remember {
+			*remembered = answer.value
+		}
+		return answer.value, nil
+	}
+}
+
+// handleHostKeyPrompt answers the host key prompt at the front of the queue
+func (m *TUIModel) handleHostKeyPrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	prompt := m.hostKeyPrompts[0]
+
+	var decision ssh.HostKeyDecision
+	switch msg.String() {
+	case "o":
+		decision = ssh.HostKeyAcceptOnce
+	case "s":
+		decision = ssh.HostKeyAcceptAndSave
+	case "r", "esc", "ctrl+c":
+		decision = ssh.HostKeyReject
+	default:
+		return m, nil
+	}
+
+	prompt.reply <- decision
+	m.hostKeyPrompts = m.hostKeyPrompts[1:]
+	return m, nil
+}
+
+// renderHostKeyPrompt renders the confirmation dialog for an unknown host key
+func (m *TUIModel) renderHostKeyPrompt() string {
+	prompt := m.hostKeyPrompts[0]
+
+	var result string
+	result += "=== UNKNOWN HOST KEY ===\n\n"
+	result += fmt.Sprintf("Connection: %s\n", prompt.alias)
+	result += fmt.Sprintf("The authenticity of host '%s' can't be established.\n\n", prompt.request.Hostname)
+	result += fmt.Sprintf("  Key type:    %s\n", prompt.request.KeyType)
+	result += fmt.Sprintf("  Fingerprint: %s\n\n", prompt.request.Fingerprint)
+	result += "Only continue if this fingerprint matches the one published for the host.\n"
+
+	if len(m.hostKeyPrompts) > 1 {
+		result += fmt.Sprintf("\n(%d more prompts waiting)\n", len(m.hostKeyPrompts)-1)
+	}
+
+	result += "\n[o]accept once [s]accept and save [r/Esc]reject\n"
+	return result
+}
+
+// handleSecretPrompt edits and submits the secret prompt at the front of the queue
+func (m *TUIModel) handleSecretPrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	prompt := m.secretPrompts[0]
+
+	switch msg.String() {
+	case "esc", "ctrl+c":
+		prompt.reply <- secretAnswer{err: ssh.ErrPromptCancelled}
+	case "enter":
+		prompt.reply <- secretAnswer{
+			value:    m.secretInput,
+			remember: prompt.canRemember && m.secretRemember,
+		}
+	case "tab":
+		m.secretRemember = !m.secretRemember
+		return m, nil
+	case "backspace":
+		runes := []rune(m.secretInput)
+		if len(runes) > 0 {
+			m.secretInput = string(runes[:len(runes)-1])
+		}
+		return m, nil
+	case "ctrl+u":
+		m.secretInput = ""
+		return m, nil
+	default:
+		if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+			m.secretInput += string(msg.Runes)
+		}
+		return m, nil
+	}
+
+	// Prompt answered, reset input for the next one
+	m.secretPrompts = m.secretPrompts[1:]
+	m.secretInput = ""
+	m.secretRemember = false
+	return m, nil
+}
+
+// renderSecretPrompt renders the masked input dialog for passwords and challenges
+func (m *TUIModel) renderSecretPrompt() string {
+	prompt := m.secretPrompts[0]
+
+	var result string
+	result += "=== AUTHENTICATION REQUIRED ===\n\n"
+	if prompt.prompt.Jump {
+		result += fmt.Sprintf("Connection: %s, jump host %s@%s\n\n", prompt.alias, prompt.prompt.User, prompt.prompt.Host)
+	} else {
+		result += fmt.Sprintf("Connection: %s (%s@%s)\n\n", prompt.alias, prompt.prompt.User, prompt.prompt.Host)
+	}
+
+	if prompt.prompt.Instruction != "" {
+		result += prompt.prompt.Instruction + "\n\n"
+	}
+
+	input := m.secretInput
+	if !prompt.prompt.Echo {
+		input = strings.Repeat("*", len([]rune(m.secretInput)))
+	}
+	result += fmt.Sprintf("%s%s█\n", prompt.prompt.Question, input)
+
+	if prompt.canRemember {
+		check := " "
+		if m.secretRemember {
+			check = "x"
+		}
+		result += fmt.Sprintf("\n[%s] Remember password in connections.json\n", check)
+	}
+
+	if len(m.secretPrompts) > 1 {
+		result += fmt.Sprintf("\n(%d more prompts waiting)\n", len(m.secretPrompts)-1)
+	}
+
+	if prompt.canRemember {
+		result += "\n[Enter]submit [Tab]toggle remember [Esc]cancel\n"
+	} else {
+		result += "\n[Enter]submit [Esc]cancel\n"
+	}
+	return result
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/SimonLariz/beacon/internal/model"
+	"github.com/SimonLariz/beacon/internal/ssh"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	reconnectBaseDelay   = 1 * time.Second  // Delay before the first reconnect attempt
+	reconnectMaxDelay    = 60 * time.Second // Backoff stops doubling here
+	maxReconnectAttempts = 8                // Attempts before giving up
+)
+
+// connectionLostMsg is sent by the keepalive loop when a connection dies
+type connectionLostMsg struct {
+	client *ssh.SSHClientWrapper
+	err    error
+}
+
+// reconnectMsg is sent once the backoff delay before a reconnect attempt has passed
+type reconnectMsg struct {
+	state *model.ConnectionState
+}
+
+// startKeepalive watches a freshly connected server for a dead link
+func (m *TUIModel) startKeepalive(cs *model.ConnectionState) {
+	client := cs.Client
+	if client == nil {
+		return
+	}
+	events := m.events
+	client.StartKeepalive(cs.Connection.KeepaliveOptions(), func(err error) {
+		events <- connectionLostMsg{client: client, err: err}
+	})
+}
+
+// disconnect tears down a live connection: its running command, file browser,
+// forwards and keepalive, leaving its status to the caller
+func (m *TUIModel) disconnect(cs *model.ConnectionState) {
+	if m.files != nil && m.files.state == cs {
+		m.closeFiles()
+	}
+	if cs.CurrentExec != nil {
+		if cancel, ok := m.cancels[cs.CurrentExec]; ok {
+			cancel()
+		}
+	}
+	cs.StopForwards()
+	if cs.Client != nil {
+		_ = cs.Client.Disconnect()
+	}
+}
+
+// connectionLost marks a connection as dead and starts reconnecting if enabled
+func (m *TUIModel) connectionLost(cs *model.ConnectionState, err error) tea.Cmd {
+	cs.LastError = err
+	if m.files != nil && m.files.state == cs {
+		// The sftp session went down with the connection
+		m.closeFiles()
+	}
+	if !cs.Connection.AutoReconnect {
+		cs.Status = model.StatusError
+		return nil
+	}
+
+	cs.Status = model.StatusReconnecting
+	cs.ReconnectAttempts = 0
+	m.setStatus(fmt.Sprintf("Lost connection to %s, reconnecting...", cs.Connection.Alias), 3*time.Second)
+	return scheduleReconnect(cs)
+}
+
+// reconnectFailed backs off before the next attempt, or gives up after maxReconnectAttempts
+func (m *TUIModel) reconnectFailed(cs *model.ConnectionState, err error) tea.Cmd {
+	cs.ReconnectAttempts++
+	if cs.ReconnectAttempts >= maxReconnectAttempts {
+		cs.Status = model.StatusError
+		cs.LastError = fmt.Errorf("gave up reconnecting after %d attempts: %w", cs.ReconnectAttempts, err)
+		return nil
+	}
+
+	cs.LastError = err
+	return scheduleReconnect(cs)
+}
+
+// scheduleReconnect waits out the backoff delay for the connection's next attempt
+func scheduleReconnect(cs *model.ConnectionState) tea.Cmd {
+	return tea.Tick(reconnectDelay(cs.ReconnectAttempts), func(time.Time) tea.Msg {
+		return reconnectMsg{state: cs}
+	})
+}
+
+// reconnectDelay doubles the delay with each failed attempt, up to reconnectMaxDelay
+func reconnectDelay(attempt int) time.Duration {
+	delay := reconnectBaseDelay
+	for i := 0; i < attempt && delay < reconnectMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > reconnectMaxDelay {
+		delay = reconnectMaxDelay
+	}
+	return delay
+}
@@ -0,0 +1,22 @@
+//go:build !unix
+
+package main
+
+import "time"
+
+// resizePollInterval is how often the terminal size is checked, there is no SIGWINCH here
+const resizePollInterval = 250 * time.Millisecond
+
+// watchResize calls resized whenever the terminal may have changed size, until done is closed
+func watchResize(done <-chan struct{}, resized func()) {
+	ticker := time.NewTicker(resizePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			resized()
+		}
+	}
+}
@@ -0,0 +1,28 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchResize calls resized whenever the terminal may have changed size, until done is closed
+// SIGWINCH reports every resize as it happens
+func watchResize(done <-chan struct{}, resized func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGWINCH)
+	defer signal.Stop(signals)
+
+	// A resize before the signal was watched would go unnoticed otherwise
+	resized()
+	for {
+		select {
+		case <-done:
+			return
+		case <-signals:
+			resized()
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/SimonLariz/beacon/internal/model"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// searchResultsShown is how many matches the history search lists
+const searchResultsShown = 8
+
+// matchStyle highlights the characters a history search matched
+var matchStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("3"))
+
+// historySearch is an active Ctrl+R search of the command history
+type historySearch struct {
+	query   string
+	global  bool // Search every connection's commands instead of the selected one's
+	matches []model.HistoryMatch
+	index   int    // Selected match
+	saved   string // Input before the search, restored on Esc
+}
+
+// historyConnection returns the connection whose own history the command
+// input uses, nil when a broadcast is being typed
+func (m *TUIModel) historyConnection() *model.Connection {
+	if len(m.AppState.MarkedConnections()) > 0 {
+		return nil
+	}
+	if selected := m.AppState.GetSelected(); selected != nil {
+		return selected.Connection
+	}
+	return nil
+}
+
+// inputHistory returns the commands up/down cycles through, oldest first
+// A command run several times in a row is only listed once
+func (m *TUIModel) inputHistory() []string {
+	if conn := m.historyConnection(); conn != nil {
+		return model.CollapseRepeats(conn.CommandHistory)
+	}
+	return model.CollapseRepeats(m.AppState.CommandHistory.Commands)
+}
+
+// configSaveDelay is how long history changes wait before the config is
+// saved, commands run in quick succession share one save
+const configSaveDelay = 2 * time.Second
+
+// saveConfigMsg is sent once a debounced config save is due
+type saveConfigMsg struct {
+	seq int
+}
+
+// recordCommand adds a command to the global history and to the history of
+// every connection it runs on, returning the debounced save of the config
+func (m *TUIModel) recordCommand(cmd string) tea.Cmd {
+	m.AppState.AddToHistory(cmd)
+	if marked := m.AppState.MarkedConnections(); len(marked) > 0 {
+		for _, cs := range marked {
+			cs.Connection.AddToHistory(cmd)
+		}
+	} else if selected := m.AppState.GetSelected(); selected != nil {
+		selected.Connection.AddToHistory(cmd)
+	}
+
+	m.unsaved = true
+	m.saveSeq++
+	seq := m.saveSeq
+	return tea.Tick(configSaveDelay, func(time.Time) tea.Msg {
+		return saveConfigMsg{seq: seq}
+	})
+}
+
+// saveConfig writes the config, including changes waiting for a debounced save
+func (m *TUIModel) saveConfig() {
+	m.unsaved = false
+	if err := model.SaveConfig(m.AppState.Config); err != nil {
+		log.Printf("Warning: failed to save config: %v", err)
+	}
+}
+
+// startSearch opens the history search, scoped to the selected connection when there is one
+func (m *TUIModel) startSearch() {
+	m.search = &historySearch{
+		global: m.historyConnection() == nil,
+		saved:  m.commandInput,
+	}
+	m.updateSearch()
+}
+
+// updateSearch reruns the search after its query or scope changed
+func (m *TUIModel) updateSearch() {
+	search := m.search
+	commands := m.AppState.CommandHistory.Commands
+	if !search.global {
+		commands = m.historyConnection().CommandHistory
+	}
+	search.matches = model.SearchHistory(commands, search.query)
+	search.index = 0
+	m.previewMatch()
+}
+
+// previewMatch puts the selected match on the input line
+func (m *TUIModel) previewMatch() {
+	if len(m.search.matches) == 0 {
+		m.commandInput = m.search.saved
+		return
+	}
+	m.commandInput = m.search.matches[m.search.index].Command
+}
+
+// handleSearchKey processes key input while searching the command history
+func (m *TUIModel) handleSearchKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	search := m.search
+	switch msg.String() {
+	case "esc", "ctrl+g":
+		m.commandInput = search.saved
+		m.search = nil
+	case "enter":
+		// Keep the match on the input line to edit or run it
+		m.search = nil
+		m.historyIndex = -1
+	case "ctrl+r", "up":
+		if search.index < len(search.matches)-1 {
+			search.index++
+			m.previewMatch()
+		}
+	case "down":
+		if search.index > 0 {
+			search.index--
+			m.previewMatch()
+		}
+	case "tab":
+		if m.historyConnection() != nil {
+			search.global = !search.global
+			m.updateSearch()
+		}
+	case "backspace":
+		if runes := []rune(search.query); len(runes) > 0 {
+			search.query = string(runes[:len(runes)-1])
+			m.updateSearch()
+		}
+	default:
+		if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+			search.query += string(msg.Runes)
+			m.updateSearch()
+		}
+	}
+	return m, nil
+}
+
+// renderSearch renders the history search prompt and its best matches
+func (m *TUIModel) renderSearch() string {
+	search := m.search
+	scope := "all hosts"
+	if !search.global {
+		scope = m.historyConnection().Alias
+	}
+
+	var result string
+	result += fmt.Sprintf("(history search: %s) %s█\n", scope, search.query)
+	if len(search.matches) == 0 {
+		result += "  no matching command\n"
+	}
+	// Scroll the list so the selected match stays visible
+	start := max(search.index-searchResultsShown+1, 0)
+	end := min(start+searchResultsShown, len(search.matches))
+	for i := start; i < end; i++ {
+		match := search.matches[i]
+		marker := "  "
+		if i == search.index {
+			marker = "> "
+		}
+		count := ""
+		if match.Count > 1 {
+			count = fmt.Sprintf("  (%d×)", match.Count)
+		}
+		result += marker + highlightMatch(match) + count + "\n"
+	}
+
+	help := "[Ctrl+R/↑↓] next match [Enter] use [Esc] cancel"
+	if m.historyConnection() != nil {
+		help = "[Ctrl+R/↑↓] next match [Tab] host/all hosts [Enter] use [Esc] cancel"
+	}
+	result += help + "\n"
+	return result
+}
+
+// highlightMatch renders a matched command with the query characters highlighted
+func highlightMatch(match model.HistoryMatch) string {
+	matched := make(map[int]bool, len(match.Positions))
+	for _, pos := range match.Positions {
+		matched[pos] = true
+	}
+	var result string
+	for i, r := range []rune(match.Command) {
+		if matched[i] {
+			result += matchStyle.Render(string(r))
+		} else {
+			result += string(r)
+		}
+	}
+	return result
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/SimonLariz/beacon/internal/ssh"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/term"
+	"github.com/muesli/cancelreader"
+)
+
+// detachKey is Ctrl+], the same escape telnet uses
+const detachKey = 0x1d
+
+// shellExitMsg is sent when an interactive shell returns control to the TUI
+type shellExitMsg struct {
+	index    int
+	detached bool
+	err      error
+}
+
+// shellCommand hands the terminal over to a remote PTY shell via tea.Exec
+type shellCommand struct {
+	client *ssh.SSHClientWrapper
+	alias  string
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	detached bool
+}
+
+func (c *shellCommand) SetStdin(r io.Reader)  { c.stdin = r }
+func (c *shellCommand) SetStdout(w io.Writer) { c.stdout = w }
+func (c *shellCommand) SetStderr(w io.Writer) { c.stderr = w }
+
+// Run puts the local terminal in raw mode and proxies it to the remote shell
+// until the shell exits or the user presses the detach key
+func (c *shellCommand) Run() error {
+	inFd := terminalFd(c.stdin, os.Stdin)
+	outFd := terminalFd(c.stdout, os.Stdout)
+
+	fmt.Fprintf(c.stdout, "Connected to %s. Press Ctrl+] to detach.\r\n", c.alias)
+
+	cols, rows, err := term.GetSize(outFd)
+	if err != nil {
+		cols, rows = 80, 24
+	}
+
+	state, err := term.MakeRaw(inFd)
+	if err != nil {
+		return fmt.Errorf("failed to enter raw mode: %w", err)
+	}
+	defer term.Restore(inFd, state)
+
+	termName := os.Getenv("TERM")
+	if termName == "" {
+		termName = "xterm-256color"
+	}
+
+	shell, err := c.client.StartShell(termName, cols, rows, c.stdout, c.stderr)
+	if err != nil {
+		return err
+	}
+	defer shell.Close()
+
+	// Cancellable so the TUI gets its keyboard back as soon as the shell ends
+	input, err := cancelreader.NewReader(c.stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read terminal input: %w", err)
+	}
+	defer input.Close()
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	// Forward keystrokes, watching for the detach key
+	go func() {
+		defer wg.Done()
+		buf := make([]byte, 1024)
+		for {
+			n, err := input.Read(buf)
+			if err != nil {
+				return
+			}
+			for i := 0; i < n; i++ {
+				if buf[i] == detachKey {
+					if i > 0 {
+						_, _ = shell.Write(buf[:i])
+					}
+					c.detached = true
+					shell.Close()
+					return
+				}
+			}
+			if _, err := shell.Write(buf[:n]); err != nil {
+				return
+			}
+		}
+	}()
+
+	// Forward terminal size changes as window-change requests
+	go func() {
+		defer wg.Done()
+		watchResize(done, func() {
+			newCols, newRows, err := term.GetSize(outFd)
+			if err == nil && (newCols != cols || newRows != rows) {
+				cols, rows = newCols, newRows
+				_ = shell.Resize(cols, rows)
+			}
+		})
+	}()
+
+	err = shell.Wait()
+	close(done)
+	input.Cancel()
+	wg.Wait()
+
+	if c.detached {
+		fmt.Fprint(c.stdout, "\r\n[detached]\r\n")
+		return nil
+	}
+	return err
+}
+
+// terminalFd returns the file descriptor behind r/w, or the fallback's
+func terminalFd(v any, fallback *os.File) uintptr {
+	if f, ok := v.(*os.File); ok {
+		return f.Fd()
+	}
+	return fallback.Fd()
+}
+
+// openShell suspends the TUI and attaches the terminal to the selected server
+func (m *TUIModel) openShell() tea.Cmd {
+	selected := m.AppState.GetSelected()
+	if selected == nil || selected.Client == nil || !selected.Client.IsConnected() {
+		m.setStatus("No connected server selected", 2*time.Second)
+		return nil
+	}
+
+	index := m.AppState.SelectedIndex
+	cmd := &shellCommand{client: selected.Client, alias: selected.Connection.Alias}
+	return tea.Exec(cmd, func(err error) tea.Msg {
+		return shellExitMsg{index: index, detached: cmd.detached, err: err}
+	})
+}
@@ -1,7 +1,10 @@
 package model
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -15,9 +18,31 @@ import (
 type Connection struct {
 	Alias   string `json:"alias"`              // User friendly name for the connection
 	Host    string `json:"host"`               // Hostname or IP address
-	Port    int    `json:"port"`               // SSH port
+	Port    int    `json:"port"`               // SSH port, 0 to use ssh config or 22
 	User    string `json:"user"`               // SSH username
 	KeyPath string `json:"key_path,omitempty"` // Optional path to SSH key
+
+	Group string   `json:"group,omitempty"` // Slash separated group path, e.g. "prod/db"
+	Tags  []string `json:"tags,omitempty"`  // Free-form labels, e.g. "web" or "env=prod"
+
+	KnownHostsFile    string `json:"known_hosts_file,omitempty"`    // Optional known_hosts override
+	Password          string `json:"password,omitempty"`            // Saved password, only if the user chose to remember it
+	NeverSavePassword bool   `json:"never_save_password,omitempty"` // Never persist the password for this connection
+
+	Jumps    []string `json:"jumps,omitempty"`    // Jump hosts in order: connection aliases or [user@]host[:port]
+	Forwards []string `json:"forwards,omitempty"` // Port forwards started on connect, e.g. "L 8080:db:5432" or "D 1080"
+
+	CommandTimeout int `json:"command_timeout,omitempty"` // Command timeout in seconds, 0 to use the global default
+
+	KeepaliveInterval  int  `json:"keepalive_interval,omitempty"`   // Seconds between keepalives, 0 for the default
+	KeepaliveMaxMisses int  `json:"keepalive_max_misses,omitempty"` // Missed keepalives before the link is dead, 0 for the default
+	AutoReconnect      bool `json:"auto_reconnect,omitempty"`       // Reconnect with backoff when the link dies
+
+	ElevateCommand string `json:"elevate_command,omitempty"` // Prefix for editing root-owned files, empty for the global default
+
+	CommandHistory []string `json:"command_history,omitempty"` // Commands run on this connection, oldest first
+
+	hostName string // HostName ssh_config resolves an imported alias to, only kept for finding duplicates
 }
 
 // CommandExecution represents a single command execution
@@ -29,6 +54,69 @@ type CommandExecution struct {
 	Stderr    string        // Standard error
 	Duration  time.Duration // How long the command took
 	Completed bool          // Whether execution is complete
+	Output    *OutputBuffer // Interleaved output, filled while the command runs
+	Outcome   ExecOutcome   // How the command ended, only meaningful once completed
+	Timeout   time.Duration // Timeout the command ran with, 0 for none
+}
+
+// ExecOutcome describes how a command execution ended
+type ExecOutcome int
+
+const (
+	OutcomeExited    ExecOutcome = iota // Command ran to completion, see ExitCode
+	OutcomeCancelled                    // Stopped by the user
+	OutcomeTimedOut                     // Stopped after exceeding its timeout
+)
+
+// String returns the outcome for display
+func (o ExecOutcome) String() string {
+	switch o {
+	case OutcomeExited:
+		return "exited"
+	case OutcomeCancelled:
+		return "cancelled"
+	case OutcomeTimedOut:
+		return "timed out"
+	default:
+		return "unknown"
+	}
+}
+
+// NewCommandExecution creates an execution for a command that is about to start
+func NewCommandExecution(cmd string) *CommandExecution {
+	return &CommandExecution{
+		Command:   cmd,
+		Timestamp: time.Now(),
+		Output:    NewOutputBuffer(DefaultOutputLimit),
+	}
+}
+
+// AppendOutput records a chunk of output from the running command
+func (e *CommandExecution) AppendOutput(stderr bool, data string) {
+	if e.Output == nil {
+		e.Output = NewOutputBuffer(DefaultOutputLimit)
+	}
+	e.Output.Append(stderr, data)
+}
+
+// Finish marks the execution complete and fills Stdout/Stderr from the streamed output
+// runErr is the context error of a command that was stopped before it exited
+func (e *CommandExecution) Finish(exitCode int, duration time.Duration, runErr error) {
+	e.ExitCode = exitCode
+	e.Duration = duration
+	e.Completed = true
+	switch {
+	case runErr == nil:
+		e.Outcome = OutcomeExited
+	case errors.Is(runErr, context.DeadlineExceeded):
+		e.Outcome = OutcomeTimedOut
+	default:
+		e.Outcome = OutcomeCancelled
+	}
+	if e.Output != nil {
+		e.Stdout = e.Output.Stream(false)
+		e.Stderr = e.Output.Stream(true)
+	}
 }
 
 // CommandHistory stores global command history
@@ -44,6 +132,7 @@ const (
 	StatusConnecting
 	StatusConnected
 	StatusError
+	StatusReconnecting
 )
 
 // ConnectionState represents the state of an SSH connection
@@ -56,28 +145,46 @@ type ConnectionState struct {
 	Output      []string              // Recent output from connection (DEPRECATED)
 	Executions  []*CommandExecution   // Full execution history
 	CurrentExec *CommandExecution     // Currently running command (if any)
+
+	ReconnectAttempts int             // Failed reconnects since the link died, reset once connected
+	Forwards          []*ForwardState // Port forwards of the current connection
+	Marked            bool            // Part of the multi-selection commands are broadcast to
 }
 
 // Config represents the saved configuration file structure
 type Config struct {
+	Version        int           `json:"version"` // Schema version, see ConfigVersion
 	Connections    []*Connection `json:"connections"`
 	CommandHistory []string      `json:"command_history,omitempty"`
+	CommandTimeout int           `json:"command_timeout,omitempty"` // Default command timeout in seconds, 0 for none
+	ElevateCommand string        `json:"elevate_command,omitempty"` // Default prefix for editing root-owned files, empty for DefaultElevateCommand
+
+	BroadcastConcurrency int `json:"broadcast_concurrency,omitempty"` // Hosts a broadcast runs on at once, 0 for the default
+
+	HistoryLimit int `json:"history_limit,omitempty"` // Executions logged per connection, 0 for the default, negative to disable
+	HistoryDays  int `json:"history_days,omitempty"`  // Days executions are logged, 0 for the default, negative for no limit
+
+	base []byte // The config as last loaded or saved by this instance, merged against on save
 }
 
+// DefaultElevateCommand is used to edit root-owned files when nothing else is configured
+// Non-interactive, since there is no terminal to type a sudo password into
+const DefaultElevateCommand = "sudo -n"
+
 // AppState represents application state
 type AppState struct {
 	Connections        []*ConnectionState // List of all connections
 	SelectedIndex      int                // Index of the currently selected connection
+	SelectedGroup      string             // Path of the selected group row, empty when a connection is selected
+	Collapsed          map[string]bool    // Groups whose members are hidden in the list
 	Config             *Config            // Loaded configuration
 	CommandHistory     *CommandHistory    // Global command history
 	OutputScrollOffset int                // Current scroll position in output
 }
 
-// NewConnection creates a new Connection with defaults
+// NewConnection creates a new Connection
+// An empty user or zero port is resolved from ~/.ssh/config (or defaults) at connect time
 func NewConnection(nickname, host, user string, port int) *Connection {
-	if port == 0 {
-		port = 22 // Default SSH port
-	}
 	return &Connection{
 		Alias: nickname,
 		Host:  host,
@@ -86,6 +193,81 @@ func NewConnection(nickname, host, user string, port int) *Connection {
 	}
 }
 
+// Address returns host:port for display, or just host when the port comes from ssh config
+func (c *Connection) Address() string {
+	if c.Port == 0 {
+		return c.Host
+	}
+	return fmt.Sprintf("%s:%d", c.Host, c.Port)
+}
+
+// ConnectOptions returns the ssh layer options for this connection
+func (c *Connection) ConnectOptions() ssh.ConnectOptions {
+	opts := ssh.ConnectOptions{
+		KnownHostsFile: c.KnownHostsFile,
+	}
+	if !c.NeverSavePassword {
+		opts.Password = c.Password
+	}
+	return opts
+}
+
+// CommandTimeoutFor returns the timeout for commands on conn
+// The connection's own setting wins over the global default, 0 means no timeout
+func (c *Config) CommandTimeoutFor(conn *Connection) time.Duration {
+	if conn.CommandTimeout > 0 {
+		return time.Duration(conn.CommandTimeout) * time.Second
+	}
+	if c.CommandTimeout > 0 {
+		return time.Duration(c.CommandTimeout) * time.Second
+	}
+	return 0
+}
+
+// ElevateCommandFor returns the command prefix used to read and write files as root on conn
+// The connection's own setting wins over the global default
+func (c *Config) ElevateCommandFor(conn *Connection) string {
+	if conn.ElevateCommand != "" {
+		return conn.ElevateCommand
+	}
+	if c.ElevateCommand != "" {
+		return c.ElevateCommand
+	}
+	return DefaultElevateCommand
+}
+
+// ParseCommandTimeout splits an optional "@<duration> " prefix off a command,
+// e.g. "@30s make test", returns a zero timeout when there is no prefix
+func ParseCommandTimeout(input string) (string, time.Duration, error) {
+	if !strings.HasPrefix(input, "@") {
+		return input, 0, nil
+	}
+
+	spec, cmd, _ := strings.Cut(input[1:], " ")
+	cmd = strings.TrimSpace(cmd)
+	if cmd == "" {
+		return "", 0, fmt.Errorf("missing command after @%s", spec)
+	}
+	timeout, err := time.ParseDuration(spec)
+	if err != nil || timeout <= 0 {
+		return "", 0, fmt.Errorf("invalid timeout %q", spec)
+	}
+	return cmd, timeout, nil
+}
+
+// KeepaliveOptions returns the keepalive settings for the ssh layer
+func (c *Connection) KeepaliveOptions() ssh.KeepaliveOptions {
+	return ssh.KeepaliveOptions{
+		Interval:  time.Duration(c.KeepaliveInterval) * time.Second,
+		MaxMisses: c.KeepaliveMaxMisses,
+	}
+}
+
+// CanSavePassword reports whether the password may be written to connections.json
+func (c *Connection) CanSavePassword() bool {
+	return !c.NeverSavePassword
+}
+
 // Helper method to get status as string
 func (cs *ConnectionState) StatusString() string {
 	switch cs.Status {
@@ -97,6 +279,8 @@ func (cs *ConnectionState) StatusString() string {
 		return "disconnected"
 	case StatusError:
 		return fmt.Sprintf("error: %v", cs.LastError)
+	case StatusReconnecting:
+		return fmt.Sprintf("reconnecting (attempt %d)", cs.ReconnectAttempts+1)
 	default:
 		return "unknown"
 	}
@@ -110,7 +294,7 @@ func NewAppState() *AppState {
 		Config:        &Config{Connections: make([]*Connection, 0), CommandHistory: make([]string, 0)},
 		CommandHistory: &CommandHistory{
 			Commands: make([]string, 0),
-			MaxSize:  1000,
+			MaxSize:  CommandHistorySize,
 		},
 		OutputScrollOffset: 0,
 	}
@@ -140,6 +324,7 @@ func LoadConfig() (*Config, error) {
 	// If file doesn't exist, return empty config
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		return &Config{
+			Version:        ConfigVersion,
 			Connections:    []*Connection{},
 			CommandHistory: make([]string, 0),
 		}, nil
@@ -150,34 +335,81 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
+	// Older files are upgraded before they are decoded
+	migrated, version, err := migrateConfig(data)
+	if err != nil {
+		if errors.Is(err, ErrConfigTooNew) {
+			return nil, err
+		}
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
-	// Handle missing CommandHistory field for backwards compatibility
-	if config.CommandHistory == nil {
-		config.CommandHistory = make([]string, 0)
+	var config Config
+	if err := json.Unmarshal(migrated, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if config.base, err = json.Marshal(&config); err != nil {
+		return nil, fmt.Errorf("failed to serialize config: %w", err)
 	}
 
+	if version < ConfigVersion {
+		// The original stays around in case the upgrade lost something
+		if err := backupConfig(configPath, data, version); err != nil {
+			return nil, err
+		}
+		if err := SaveConfig(&config); err != nil {
+			return nil, err
+		}
+	}
 	return &config, nil
 }
 
 // SaveConfig saves the configuration to the config file
+// Changes another beacon instance saved since this config was loaded are
+// merged in rather than overwritten, see mergeConfig
 func SaveConfig(config *Config) error {
 	configPath, err := ConfigPath()
 	if err != nil {
 		return err
 	}
 
-	data, err := json.MarshalIndent(config, "", "  ")
+	// Other instances wait until the merged config is written
+	unlock, err := lockConfig(configPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	config.Version = ConfigVersion
+	ours, err := json.Marshal(config)
 	if err != nil {
 		return fmt.Errorf("failed to serialize config: %w", err)
 	}
 
-	if err := os.WriteFile(configPath, data, 0600); err != nil {
+	saved := config
+	theirs, err := readConfigFile(configPath)
+	if err != nil {
+		return err
+	}
+	if theirs != nil && !bytes.Equal(theirs, config.base) {
+		merged, err := mergeConfig(config.base, ours, theirs)
+		if err != nil {
+			return fmt.Errorf("failed to merge config: %w", err)
+		}
+		saved = &Config{}
+		if err := json.Unmarshal(merged, saved); err != nil {
+			return fmt.Errorf("failed to merge config: %w", err)
+		}
+	}
+
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize config: %w", err)
+	}
+	if err := writeFileAtomic(configPath, data, 0600); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
+	config.base = ours
 	return nil
 }
 
@@ -192,6 +424,52 @@ func (app *AppState) AddConnection(conn *Connection) {
 	})
 }
 
+// RemoveConnection removes the connection with the given alias from the config
+func (c *Config) RemoveConnection(alias string) error {
+	for i, conn := range c.Connections {
+		if conn.Alias == alias {
+			c.Connections = append(c.Connections[:i], c.Connections[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("no connection %q", alias)
+}
+
+// RenameConnection changes a connection's alias, updating the jump hosts of
+// other connections that go through it
+func (c *Config) RenameConnection(conn *Connection, alias string) error {
+	if alias == conn.Alias {
+		return nil
+	}
+	if c.FindConnection(alias) != nil {
+		return fmt.Errorf("connection %q already exists", alias)
+	}
+	for _, other := range c.Connections {
+		for i, hop := range other.Jumps {
+			if strings.TrimSpace(hop) == conn.Alias {
+				other.Jumps[i] = alias
+			}
+		}
+	}
+	conn.Alias = alias
+	return nil
+}
+
+// SelectConnections returns the connections a command line target names
+// An exact alias wins, otherwise the target is a pattern, see Connection.Matches
+func (c *Config) SelectConnections(target string) []*Connection {
+	if conn := c.FindConnection(target); conn != nil {
+		return []*Connection{conn}
+	}
+	var selected []*Connection
+	for _, conn := range c.Connections {
+		if conn.Matches(target) {
+			selected = append(selected, conn)
+		}
+	}
+	return selected
+}
+
 // DeleteConnection deletes a connection from the app state
 func (app *AppState) DeleteConnection(index int) error {
 	if index < 0 || index >= len(app.Config.Connections) {
@@ -206,55 +484,33 @@ func (app *AppState) DeleteConnection(index int) error {
 	if app.SelectedIndex >= len(app.Connections) && app.SelectedIndex > 0 {
 		app.SelectedIndex--
 	}
+	// The next connection may sit in a collapsed group
+	app.SelectConnection(app.SelectedIndex)
 	return nil
 }
 
-// GetSelected returns the currently selected connection
+// GetSelected returns the currently selected connection, nil when a group is selected
 func (app *AppState) GetSelected() *ConnectionState {
-	if app.SelectedIndex < 0 || app.SelectedIndex >= len(app.Connections) {
+	if app.SelectedGroup != "" || app.SelectedIndex < 0 || app.SelectedIndex >= len(app.Connections) {
 		return nil
 	}
 	return app.Connections[app.SelectedIndex]
 }
 
-// SelectNext selects the next connection in the list
+// SelectNext selects the next row of the connection tree
 func (app *AppState) SelectNext() {
-	if len(app.Connections) == 0 {
-		return
-	}
-	app.SelectedIndex = (app.SelectedIndex + 1) % len(app.Connections)
+	app.moveSelection(1)
 }
 
-// SelectPrevious selects the previous connection in the list
+// SelectPrevious selects the previous row of the connection tree
 func (app *AppState) SelectPrevious() {
-	if len(app.Connections) == 0 {
-		return
-	}
-	app.SelectedIndex = (app.SelectedIndex - 1 + len(app.Connections)) % len(app.Connections)
+	app.moveSelection(-1)
 }
 
 // AddToHistory adds a command to global history
 func (app *AppState) AddToHistory(cmd string) {
-	if cmd == "" {
-		return
-	}
-
-	// Avoid duplicates (if last command is same, don't add)
-	if len(app.CommandHistory.Commands) > 0 {
-		last := app.CommandHistory.Commands[len(app.CommandHistory.Commands)-1]
-		if last == cmd {
-			return
-		}
-	}
-
-	app.CommandHistory.Commands = append(app.CommandHistory.Commands, cmd)
+	app.CommandHistory.Commands = appendHistory(app.CommandHistory.Commands, cmd, app.CommandHistory.MaxSize)
 	app.Config.CommandHistory = app.CommandHistory.Commands
-
-	// Enforce max size
-	if len(app.CommandHistory.Commands) > app.CommandHistory.MaxSize {
-		app.CommandHistory.Commands = app.CommandHistory.Commands[1:]
-		app.Config.CommandHistory = app.CommandHistory.Commands
-	}
 }
 
 // GetHistoryItem retrieves command at index (in reverse order, most recent first)
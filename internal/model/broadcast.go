@@ -0,0 +1,178 @@
+package model
+
+import (
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBroadcastConcurrency is how many hosts a broadcast runs on at once
+// when the config doesn't say otherwise
+const DefaultBroadcastConcurrency = 8
+
+// Broadcast is one command run on several connections
+type Broadcast struct {
+	Command   string
+	Timestamp time.Time
+	Hosts     []*BroadcastHost
+}
+
+// BroadcastHost is the part of a broadcast running on one connection
+type BroadcastHost struct {
+	State     *ConnectionState
+	Execution *CommandExecution // Nil when the host was skipped
+	Skipped   string            // Why the command didn't run here
+	Err       error             // Connection error, the command never ran to an exit
+
+	started atomic.Pointer[time.Time] // When the host got a slot under the concurrency limit, nil while queued
+}
+
+// Start records that the host got a slot and its command is starting
+func (h *BroadcastHost) Start() {
+	now := time.Now()
+	h.started.Store(&now)
+}
+
+// StartedAt returns when the host's command started, the zero time while it is queued
+func (h *BroadcastHost) StartedAt() time.Time {
+	if started := h.started.Load(); started != nil {
+		return *started
+	}
+	return time.Time{}
+}
+
+// BroadcastStatus is where a host is in a broadcast
+type BroadcastStatus int
+
+const (
+	BroadcastQueued BroadcastStatus = iota
+	BroadcastRunning
+	BroadcastDone
+	BroadcastFailed
+	BroadcastSkipped
+)
+
+// String returns the status for display
+func (s BroadcastStatus) String() string {
+	switch s {
+	case BroadcastQueued:
+		return "queued"
+	case BroadcastRunning:
+		return "running"
+	case BroadcastDone:
+		return "done"
+	case BroadcastFailed:
+		return "failed"
+	case BroadcastSkipped:
+		return "skipped"
+	default:
+		return "unknown"
+	}
+}
+
+// Status returns where the host is in the broadcast
+func (h *BroadcastHost) Status() BroadcastStatus {
+	switch {
+	case h.Execution == nil:
+		return BroadcastSkipped
+	case h.Err != nil:
+		return BroadcastFailed
+	case h.Execution.Completed:
+		return BroadcastDone
+	case h.started.Load() != nil:
+		return BroadcastRunning
+	default:
+		return BroadcastQueued
+	}
+}
+
+// Finished returns how many hosts are no longer queued or running
+func (b *Broadcast) Finished() int {
+	finished := 0
+	for _, h := range b.Hosts {
+		if s := h.Status(); s != BroadcastQueued && s != BroadcastRunning {
+			finished++
+		}
+	}
+	return finished
+}
+
+// Host returns the broadcast entry of an execution, nil if it isn't part of it
+func (b *Broadcast) Host(exec *CommandExecution) *BroadcastHost {
+	for _, h := range b.Hosts {
+		if h.Execution == exec {
+			return h
+		}
+	}
+	return nil
+}
+
+// BroadcastConcurrencyFor returns how many hosts a broadcast runs on at once
+func (c *Config) BroadcastConcurrencyFor() int {
+	if c.BroadcastConcurrency > 0 {
+		return c.BroadcastConcurrency
+	}
+	return DefaultBroadcastConcurrency
+}
+
+// Matches reports whether a connection matches a selection pattern
+// The pattern is a glob matched against the alias and the host, or with a
+// group: or tag: prefix against the connection's groups or tags
+func (c *Connection) Matches(pattern string) bool {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return false
+	}
+	if group, ok := strings.CutPrefix(pattern, GroupSelector); ok {
+		return c.InGroup(group)
+	}
+	if tag, ok := strings.CutPrefix(pattern, TagSelector); ok {
+		return c.HasTag(tag)
+	}
+	for _, name := range []string{c.Alias, c.Host} {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ToggleMarked adds or removes a connection from the multi-selection
+func (app *AppState) ToggleMarked(index int) {
+	if index < 0 || index >= len(app.Connections) {
+		return
+	}
+	app.Connections[index].Marked = !app.Connections[index].Marked
+}
+
+// MarkMatching adds every connection matching pattern to the multi-selection
+// Returns how many connections matched
+func (app *AppState) MarkMatching(pattern string) int {
+	matched := 0
+	for _, cs := range app.Connections {
+		if cs.Connection.Matches(pattern) {
+			cs.Marked = true
+			matched++
+		}
+	}
+	return matched
+}
+
+// ClearMarked empties the multi-selection
+func (app *AppState) ClearMarked() {
+	for _, cs := range app.Connections {
+		cs.Marked = false
+	}
+}
+
+// MarkedConnections returns the connections in the multi-selection, in list order
+func (app *AppState) MarkedConnections() []*ConnectionState {
+	var marked []*ConnectionState
+	for _, cs := range app.Connections {
+		if cs.Marked {
+			marked = append(marked, cs)
+		}
+	}
+	return marked
+}
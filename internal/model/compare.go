@@ -0,0 +1,68 @@
+package model
+
+import "sort"
+
+// OutputGroup is a set of connections whose command output was identical
+type OutputGroup struct {
+	Output     string
+	Executions []*CommandExecution // Parallel to States
+	States     []*ConnectionState
+}
+
+// OutputComparison groups the latest output of one command across connections
+// Groups are ordered largest first, the first group is the majority
+type OutputComparison struct {
+	Command string
+	Groups  []*OutputGroup
+	Missing []*ConnectionState // Connections that never completed the command
+}
+
+// LatestExecution returns the most recent completed run of command on the connection
+func (cs *ConnectionState) LatestExecution(command string) *CommandExecution {
+	for i := len(cs.Executions) - 1; i >= 0; i-- {
+		if exec := cs.Executions[i]; exec.Completed && exec.Command == command {
+			return exec
+		}
+	}
+	return nil
+}
+
+// CombinedOutput returns stdout followed by stderr, the way outputs are compared
+func (e *CommandExecution) CombinedOutput() string {
+	if e.Stderr == "" {
+		return e.Stdout
+	}
+	out := e.Stdout
+	if out != "" && out[len(out)-1] != '\n' {
+		out += "\n"
+	}
+	return out + "--- stderr ---\n" + e.Stderr
+}
+
+// CompareOutputs groups connections by the output of their latest run of command
+// Ties between equally large groups keep connection order
+func CompareOutputs(states []*ConnectionState, command string) *OutputComparison {
+	comparison := &OutputComparison{Command: command}
+	byOutput := make(map[string]*OutputGroup)
+	for _, cs := range states {
+		exec := cs.LatestExecution(command)
+		if exec == nil {
+			comparison.Missing = append(comparison.Missing, cs)
+			continue
+		}
+		output := exec.CombinedOutput()
+		group, ok := byOutput[output]
+		if !ok {
+			group = &OutputGroup{Output: output}
+			byOutput[output] = group
+			comparison.Groups = append(comparison.Groups, group)
+		}
+		group.Executions = append(group.Executions, exec)
+		group.States = append(group.States, cs)
+	}
+
+	sort.SliceStable(comparison.Groups, func(i, j int) bool {
+		return len(comparison.Groups[i].States) > len(comparison.Groups[j].States)
+	})
+	return comparison
+}
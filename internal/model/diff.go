@@ -0,0 +1,241 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MaxDiffEdits bounds the number of changed lines DiffLines searches for,
+// the trace it keeps for backtracking grows with its square
+const MaxDiffEdits = 1000
+
+// ErrDiffTooLarge is returned when two versions differ in too many lines to diff
+var ErrDiffTooLarge = errors.New("too many differences to diff")
+
+// DiffOp is what happened to a line between two versions
+type DiffOp int
+
+const (
+	DiffEqual  DiffOp = iota // Line is in both versions
+	DiffDelete               // Line is only in the old version
+	DiffInsert               // Line is only in the new version
+)
+
+// DiffLine is one line of a diff
+type DiffLine struct {
+	Op   DiffOp
+	Text string
+}
+
+// SplitLines splits text into lines for diffing, ignoring a trailing newline
+func SplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// DiffLines returns a shortest edit script turning a into b (Myers' algorithm)
+// Versions needing more than MaxDiffEdits changes return ErrDiffTooLarge
+func DiffLines(a, b []string) ([]DiffLine, error) {
+	n, m := len(a), len(b)
+	maxD := min(n+m, MaxDiffEdits)
+	if n+m == 0 {
+		return nil, nil
+	}
+
+	// v[k+offset] is the furthest x reached on diagonal k, trace keeps the
+	// diagonals -d-1..d+1 of every round for backtracking
+	offset := maxD + 1
+	v := make([]int, 2*maxD+3)
+	var trace [][]int
+	var d int
+search:
+	for d = 0; d <= maxD; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+	if d > maxD {
+		return nil, ErrDiffTooLarge
+	}
+
+	// Walk back from the end, collecting lines in reverse
+	var lines []DiffLine
+	x, y := n, m
+	for ; d >= 0; d-- {
+		round := trace[d]
+		at := func(k int) int { return round[k+d+1] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			lines = append(lines, DiffLine{Op: DiffEqual, Text: a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				lines = append(lines, DiffLine{Op: DiffInsert, Text: b[y-1]})
+			} else {
+				lines = append(lines, DiffLine{Op: DiffDelete, Text: a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines, nil
+}
+
+// UnifiedDiff formats the changes from a to b like diff -u, with context
+// unchanged lines around each change, returns nil when they are equal
+func UnifiedDiff(aName, bName string, a, b []string, context int) ([]string, error) {
+	lines, err := DiffLines(a, b)
+	if err != nil {
+		return nil, err
+	}
+
+	// Line numbers in a and b before each diff line
+	aLine := make([]int, len(lines)+1)
+	bLine := make([]int, len(lines)+1)
+	for i, line := range lines {
+		aLine[i+1], bLine[i+1] = aLine[i], bLine[i]
+		if line.Op != DiffInsert {
+			aLine[i+1]++
+		}
+		if line.Op != DiffDelete {
+			bLine[i+1]++
+		}
+	}
+
+	var out []string
+	for i := 0; i < len(lines); {
+		if lines[i].Op == DiffEqual {
+			i++
+			continue
+		}
+
+		// Changes separated by at most 2*context equal lines share a hunk
+		start := max(i-context, 0)
+		end := i
+		for {
+			for end < len(lines) && lines[end].Op != DiffEqual {
+				end++
+			}
+			next := end
+			for next < len(lines) && lines[next].Op == DiffEqual {
+				next++
+			}
+			if next == len(lines) || next-end > 2*context {
+				break
+			}
+			end = next
+		}
+		end = min(end+context, len(lines))
+
+		if out == nil {
+			out = append(out, "--- "+aName, "+++ "+bName)
+		}
+		out = append(out, fmt.Sprintf("@@ -%s +%s @@",
+			hunkRange(aLine[start], aLine[end]-aLine[start]),
+			hunkRange(bLine[start], bLine[end]-bLine[start])))
+		for _, line := range lines[start:end] {
+			switch line.Op {
+			case DiffEqual:
+				out = append(out, " "+line.Text)
+			case DiffDelete:
+				out = append(out, "-"+line.Text)
+			case DiffInsert:
+				out = append(out, "+"+line.Text)
+			}
+		}
+		i = end
+	}
+	return out, nil
+}
+
+// hunkRange formats the start,count of a hunk header, start is 1-based
+// except for empty ranges, which name the line before them like diff does
+func hunkRange(before, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", before+1)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
+
+// DiffRow is one row of a side-by-side diff
+// A side is empty when the line only exists in the other version
+type DiffRow struct {
+	Op    DiffOp // DiffEqual, or DiffDelete/DiffInsert for a changed row
+	Left  string
+	Right string
+
+	HasLeft  bool
+	HasRight bool
+}
+
+// SideBySide lays a diff out in two columns, pairing deleted lines with the
+// lines inserted in their place
+func SideBySide(lines []DiffLine) []DiffRow {
+	var rows []DiffRow
+	for i := 0; i < len(lines); {
+		if lines[i].Op == DiffEqual {
+			rows = append(rows, DiffRow{Op: DiffEqual, Left: lines[i].Text, Right: lines[i].Text, HasLeft: true, HasRight: true})
+			i++
+			continue
+		}
+
+		// A change block is deletions followed by insertions
+		var deleted, inserted []string
+		for ; i < len(lines) && lines[i].Op == DiffDelete; i++ {
+			deleted = append(deleted, lines[i].Text)
+		}
+		for ; i < len(lines) && lines[i].Op == DiffInsert; i++ {
+			inserted = append(inserted, lines[i].Text)
+		}
+		for j := 0; j < max(len(deleted), len(inserted)); j++ {
+			row := DiffRow{Op: DiffDelete}
+			if j < len(deleted) {
+				row.Left, row.HasLeft = deleted[j], true
+			}
+			if j < len(inserted) {
+				row.Right, row.HasRight = inserted[j], true
+			}
+			if !row.HasLeft {
+				row.Op = DiffInsert
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
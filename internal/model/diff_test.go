@@ -0,0 +1,249 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// script writes a diff as "=line", "-line" and "+line" for comparing
+func script(lines []DiffLine) []string {
+	var out []string
+	for _, line := range lines {
+		out = append(out, string("=-+"[line.Op])+line.Text)
+	}
+	return out
+}
+
+// numbered returns the lines "from" through "to"
+func numbered(from, to int) []string {
+	var lines []string
+	for i := from; i <= to; i++ {
+		lines = append(lines, strconv.Itoa(i))
+	}
+	return lines
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []string
+		want []string
+	}{
+		{name: "both empty"},
+		{name: "from empty", b: []string{"a", "b"}, want: []string{"+a", "+b"}},
+		{name: "to empty", a: []string{"a", "b"}, want: []string{"-a", "-b"}},
+		{name: "identical", a: []string{"a", "b"}, b: []string{"a", "b"}, want: []string{"=a", "=b"}},
+		{name: "insert at start", a: []string{"b", "c"}, b: []string{"a", "b", "c"}, want: []string{"+a", "=b", "=c"}},
+		{name: "insert at end", a: []string{"a", "b"}, b: []string{"a", "b", "c"}, want: []string{"=a", "=b", "+c"}},
+		{name: "delete at start", a: []string{"a", "b", "c"}, b: []string{"b", "c"}, want: []string{"-a", "=b", "=c"}},
+		{name: "delete at end", a: []string{"a", "b", "c"}, b: []string{"a", "b"}, want: []string{"=a", "=b", "-c"}},
+		{name: "replace", a: []string{"a", "b", "c"}, b: []string{"a", "x", "c"}, want: []string{"=a", "-b", "+x", "=c"}},
+		{name: "nothing in common", a: []string{"a"}, b: []string{"b"}, want: []string{"-a", "+b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := DiffLines(tt.a, tt.b)
+			if err != nil {
+				t.Fatalf("DiffLines: %v", err)
+			}
+			if got := script(lines); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// lcsLength returns the length of the longest common subsequence of a and b
+func lcsLength(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	for i := range a {
+		cur := make([]int, len(b)+1)
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(prev[j+1], cur[j])
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func TestDiffLinesShortest(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random := func() []string {
+		lines := make([]string, rng.Intn(12))
+		for i := range lines {
+			lines[i] = string(rune('a' + rng.Intn(3)))
+		}
+		return lines
+	}
+	for i := 0; i < 2000; i++ {
+		a, b := random(), random()
+		lines, err := DiffLines(a, b)
+		if err != nil {
+			t.Fatalf("DiffLines(%q, %q): %v", a, b, err)
+		}
+
+		// The script must turn a into b with as few edits as possible
+		var fromA, fromB []string
+		edits := 0
+		for _, line := range lines {
+			if line.Op != DiffInsert {
+				fromA = append(fromA, line.Text)
+			}
+			if line.Op != DiffDelete {
+				fromB = append(fromB, line.Text)
+			}
+			if line.Op != DiffEqual {
+				edits++
+			}
+		}
+		if fmt.Sprint(fromA) != fmt.Sprint(a) || fmt.Sprint(fromB) != fmt.Sprint(b) {
+			t.Fatalf("DiffLines(%q, %q) = %q does not turn one into the other", a, b, script(lines))
+		}
+		if want := len(a) + len(b) - 2*lcsLength(a, b); edits != want {
+			t.Fatalf("DiffLines(%q, %q) = %q has %d edits, want %d", a, b, script(lines), edits, want)
+		}
+	}
+}
+
+func TestDiffLinesTooLarge(t *testing.T) {
+	half := MaxDiffEdits / 2
+	atCap := numbered(1, half)
+	other := numbered(half+1, 2*half)
+	if _, err := DiffLines(atCap, other); err != nil {
+		t.Errorf("%d edits: %v", MaxDiffEdits, err)
+	}
+
+	overCap := append(numbered(1, half), "extra")
+	if _, err := DiffLines(overCap, other); !errors.Is(err, ErrDiffTooLarge) {
+		t.Errorf("%d edits: got %v, want ErrDiffTooLarge", MaxDiffEdits+1, err)
+	}
+	if _, err := UnifiedDiff("a", "b", overCap, other, 3); !errors.Is(err, ErrDiffTooLarge) {
+		t.Errorf("UnifiedDiff: got %v, want ErrDiffTooLarge", err)
+	}
+
+	// Long inputs are fine as long as they differ in few lines
+	long := numbered(1, 10*MaxDiffEdits)
+	changed := append([]string{"first"}, long[1:]...)
+	if _, err := DiffLines(long, changed); err != nil {
+		t.Errorf("one change in %d lines: %v", len(long), err)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	replace := func(lines []string, changes map[int]string) []string {
+		out := append([]string(nil), lines...)
+		for i, line := range changes {
+			out[i-1] = line
+		}
+		return out
+	}
+
+	tests := []struct {
+		name    string
+		a, b    []string
+		context int
+		want    []string
+	}{
+		{name: "identical", a: numbered(1, 3), b: numbered(1, 3), context: 3},
+		{name: "both empty", context: 3},
+		{
+			name: "from empty", b: []string{"a"}, context: 3,
+			want: []string{"--- old", "+++ new", "@@ -0,0 +1 @@", "+a"},
+		},
+		{
+			name: "to empty", a: []string{"a", "b"}, context: 3,
+			want: []string{"--- old", "+++ new", "@@ -1,2 +0,0 @@", "-a", "-b"},
+		},
+		{
+			name: "change at start", a: numbered(1, 10), b: replace(numbered(1, 10), map[int]string{1: "X"}), context: 3,
+			want: []string{"--- old", "+++ new", "@@ -1,4 +1,4 @@", "-1", "+X", " 2", " 3", " 4"},
+		},
+		{
+			name: "delete at end", a: numbered(1, 5), b: numbered(1, 4), context: 2,
+			want: []string{"--- old", "+++ new", "@@ -3,3 +3,2 @@", " 3", " 4", "-5"},
+		},
+		{
+			name: "overlapping context merges hunks",
+			a:    numbered(1, 6), b: replace(numbered(1, 6), map[int]string{2: "X", 5: "Y"}), context: 1,
+			want: []string{"--- old", "+++ new", "@@ -1,6 +1,6 @@",
+				" 1", "-2", "+X", " 3", " 4", "-5", "+Y", " 6"},
+		},
+		{
+			name: "separate hunks",
+			a:    numbered(1, 7), b: replace(numbered(1, 7), map[int]string{2: "X", 6: "Y"}), context: 1,
+			want: []string{"--- old", "+++ new",
+				"@@ -1,3 +1,3 @@", " 1", "-2", "+X", " 3",
+				"@@ -5,3 +5,3 @@", " 5", "-6", "+Y", " 7"},
+		},
+		{
+			name: "no context", a: numbered(1, 3), b: []string{"1", "3"}, context: 0,
+			want: []string{"--- old", "+++ new", "@@ -2 +1,0 @@", "-2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := UnifiedDiff("old", "new", tt.a, tt.b, tt.context)
+			if err != nil {
+				t.Fatalf("UnifiedDiff: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSideBySide(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []string
+		want []DiffRow
+	}{
+		{
+			name: "equal",
+			a:    []string{"a"}, b: []string{"a"},
+			want: []DiffRow{{Op: DiffEqual, Left: "a", Right: "a", HasLeft: true, HasRight: true}},
+		},
+		{
+			name: "replacement pairs up",
+			a:    []string{"a", "b", "c"}, b: []string{"a", "x", "c"},
+			want: []DiffRow{
+				{Op: DiffEqual, Left: "a", Right: "a", HasLeft: true, HasRight: true},
+				{Op: DiffDelete, Left: "b", Right: "x", HasLeft: true, HasRight: true},
+				{Op: DiffEqual, Left: "c", Right: "c", HasLeft: true, HasRight: true},
+			},
+		},
+		{
+			name: "more deleted than inserted",
+			a:    []string{"a", "b"}, b: []string{"x"},
+			want: []DiffRow{
+				{Op: DiffDelete, Left: "a", Right: "x", HasLeft: true, HasRight: true},
+				{Op: DiffDelete, Left: "b", HasLeft: true},
+			},
+		},
+		{
+			name: "only inserted",
+			b:    []string{"x"},
+			want: []DiffRow{{Op: DiffInsert, Right: "x", HasRight: true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := DiffLines(tt.a, tt.b)
+			if err != nil {
+				t.Fatalf("DiffLines: %v", err)
+			}
+			if got := SideBySide(lines); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
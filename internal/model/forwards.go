@@ -0,0 +1,130 @@
+package model
+
+import (
+	"fmt"
+
+	"github.com/SimonLariz/beacon/internal/ssh"
+)
+
+// ForwardState tracks one configured port forward on a live connection
+type ForwardState struct {
+	Spec    string       // As configured in Connection.Forwards
+	Forward *ssh.Forward // Running forward, nil if it couldn't be started
+	Err     error        // Why the forward couldn't be started
+}
+
+// Stats returns the forward's traffic, zero if it isn't running
+func (f *ForwardState) Stats() ssh.ForwardStats {
+	if f.Forward == nil {
+		return ssh.ForwardStats{Closed: true, LastError: f.Err}
+	}
+	return f.Forward.Stats()
+}
+
+// StartForwards starts every configured forward on the connected client
+// Forwards that fail keep their error, the rest still start
+func (cs *ConnectionState) StartForwards() {
+	cs.StopForwards()
+	for _, spec := range cs.Connection.Forwards {
+		cs.Forwards = append(cs.Forwards, cs.startForward(spec))
+	}
+}
+
+// FailedForwards returns how many forwards couldn't be started
+func (cs *ConnectionState) FailedForwards() int {
+	failed := 0
+	for _, f := range cs.Forwards {
+		if f.Err != nil {
+			failed++
+		}
+	}
+	return failed
+}
+
+// StopForwards closes the running forwards of the connection
+func (cs *ConnectionState) StopForwards() {
+	for _, f := range cs.Forwards {
+		if f.Forward != nil {
+			f.Forward.Close()
+		}
+	}
+	cs.Forwards = nil
+}
+
+// startForward parses and starts one forward
+func (cs *ConnectionState) startForward(spec string) *ForwardState {
+	state := &ForwardState{Spec: spec}
+	parsed, err := ssh.ParseForwardSpec(spec)
+	if err != nil {
+		state.Err = err
+		return state
+	}
+	if cs.Client == nil || !cs.Client.IsConnected() {
+		state.Err = fmt.Errorf("not connected")
+		return state
+	}
+	state.Forward, state.Err = cs.Client.StartForward(parsed)
+	return state
+}
+
+// AddForward adds a forward to the connection and starts it if connected
+// The spec is stored in canonical form, the caller saves the config
+// Returns a nil state when the connection isn't up
+func (cs *ConnectionState) AddForward(spec string) (*ForwardState, error) {
+	parsed, err := ssh.ParseForwardSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	spec = parsed.String()
+	for _, existing := range cs.Connection.Forwards {
+		if existing == spec {
+			return nil, fmt.Errorf("forward %q already exists", spec)
+		}
+	}
+
+	cs.Connection.Forwards = append(cs.Connection.Forwards, spec)
+	if cs.Client == nil || !cs.Client.IsConnected() {
+		// Started with the others on the next connect
+		return nil, nil
+	}
+	state := cs.startForward(spec)
+	cs.Forwards = append(cs.Forwards, state)
+	return state, nil
+}
+
+// ForwardState returns the live state of a configured forward, nil if it isn't started
+func (cs *ConnectionState) ForwardState(spec string) *ForwardState {
+	for _, f := range cs.Forwards {
+		if f.Spec == spec {
+			return f
+		}
+	}
+	return nil
+}
+
+// RemoveForward stops a forward and removes it from the connection
+// The caller saves the config
+func (cs *ConnectionState) RemoveForward(spec string) error {
+	found := false
+	for i, existing := range cs.Connection.Forwards {
+		if existing == spec {
+			cs.Connection.Forwards = append(cs.Connection.Forwards[:i], cs.Connection.Forwards[i+1:]...)
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("no forward %q", spec)
+	}
+
+	for i, state := range cs.Forwards {
+		if state.Spec == spec {
+			if state.Forward != nil {
+				state.Forward.Close()
+			}
+			cs.Forwards = append(cs.Forwards[:i], cs.Forwards[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
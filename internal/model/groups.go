@@ -0,0 +1,266 @@
+package model
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Prefixes of selection patterns that match on a group or tag instead of the alias and host
+const (
+	GroupSelector = "group:"
+	TagSelector   = "tag:"
+)
+
+// NormalizeGroup cleans a group path, e.g. " /prod//db/ " becomes "prod/db"
+func NormalizeGroup(group string) string {
+	var parts []string
+	for _, part := range strings.Split(group, "/") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// ParseTags splits a comma or space separated list of tags, dropping duplicates
+func ParseTags(list string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, tag := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// InGroup reports whether the connection is in a group matching pattern or
+// in one of its subgroups, so "prod" selects "prod/db" and "prod/web"
+func (c *Connection) InGroup(pattern string) bool {
+	pattern = NormalizeGroup(pattern)
+	if pattern == "" || c.Group == "" {
+		return false
+	}
+	parts := strings.Split(c.Group, "/")
+	for i := range parts {
+		if ok, _ := path.Match(pattern, strings.Join(parts[:i+1], "/")); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// HasTag reports whether one of the connection's tags matches pattern
+func (c *Connection) HasTag(pattern string) bool {
+	for _, tag := range c.Tags {
+		if ok, _ := path.Match(pattern, tag); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// GroupCounts summarizes the connections of a group and its subgroups
+type GroupCounts struct {
+	Hosts     int
+	Connected int
+	Errors    int
+}
+
+// String returns the counts for display
+func (g GroupCounts) String() string {
+	s := fmt.Sprintf("%d host(s)", g.Hosts)
+	if g.Connected > 0 {
+		s += fmt.Sprintf(", %d connected", g.Connected)
+	}
+	if g.Errors > 0 {
+		s += fmt.Sprintf(", %d error(s)", g.Errors)
+	}
+	return s
+}
+
+// TreeRow is one visible row of the connection tree
+type TreeRow struct {
+	Depth int    // Nesting level, 0 for top level rows
+	Group string // Full path of a group row, empty for a connection row
+	Index int    // Index in Connections of a connection row, -1 for a group row
+
+	Name      string      // Last element of the group path
+	Counts    GroupCounts // Totals of a group row
+	Collapsed bool        // Whether a group row hides its members
+}
+
+// treeNode is a group while the tree is built
+type treeNode struct {
+	path     string
+	members  []int // Indexes of the connections directly in the group
+	children map[string]*treeNode
+	counts   GroupCounts
+}
+
+// TreeRows lays the connections out as a tree of groups
+// Every level lists its own connections in config order, then its subgroups
+// by name, members of collapsed groups are left out
+func (app *AppState) TreeRows() []TreeRow {
+	root := &treeNode{children: make(map[string]*treeNode)}
+	for i, cs := range app.Connections {
+		node := root
+		if cs.Connection.Group != "" {
+			for _, part := range strings.Split(cs.Connection.Group, "/") {
+				child, ok := node.children[part]
+				if !ok {
+					child = &treeNode{path: path.Join(node.path, part), children: make(map[string]*treeNode)}
+					node.children[part] = child
+				}
+				child.counts.add(cs)
+				node = child
+			}
+		}
+		node.members = append(node.members, i)
+	}
+
+	var rows []TreeRow
+	var walk func(node *treeNode, depth int)
+	walk = func(node *treeNode, depth int) {
+		for _, i := range node.members {
+			rows = append(rows, TreeRow{Depth: depth, Index: i})
+		}
+		names := make([]string, 0, len(node.children))
+		for name := range node.children {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			child := node.children[name]
+			collapsed := app.Collapsed[child.path]
+			rows = append(rows, TreeRow{Depth: depth, Group: child.path, Index: -1,
+				Name: name, Counts: child.counts, Collapsed: collapsed})
+			if !collapsed {
+				walk(child, depth+1)
+			}
+		}
+	}
+	walk(root, 0)
+	return rows
+}
+
+// add counts a connection in the group's totals
+func (g *GroupCounts) add(cs *ConnectionState) {
+	g.Hosts++
+	switch cs.Status {
+	case StatusConnected:
+		g.Connected++
+	case StatusError:
+		g.Errors++
+	}
+}
+
+// selectedRow returns the position of the selection in rows, -1 when it's hidden
+func (app *AppState) selectedRow(rows []TreeRow) int {
+	for i, row := range rows {
+		if (app.SelectedGroup != "" && row.Group == app.SelectedGroup) ||
+			(app.SelectedGroup == "" && row.Index >= 0 && row.Index == app.SelectedIndex) {
+			return i
+		}
+	}
+	return -1
+}
+
+// selectRow moves the selection to a row of the tree
+func (app *AppState) selectRow(row TreeRow) {
+	if row.Index >= 0 {
+		app.SelectedIndex = row.Index
+		app.SelectedGroup = ""
+	} else {
+		app.SelectedGroup = row.Group
+	}
+}
+
+// moveSelection moves the selection by delta visible rows, wrapping around
+func (app *AppState) moveSelection(delta int) {
+	rows := app.TreeRows()
+	if len(rows) == 0 {
+		return
+	}
+	i := app.selectedRow(rows)
+	if i < 0 {
+		app.selectRow(rows[0])
+		return
+	}
+	app.selectRow(rows[(i+delta+len(rows))%len(rows)])
+}
+
+// SelectConnection selects a connection, expanding the groups it is in
+func (app *AppState) SelectConnection(index int) {
+	if index < 0 || index >= len(app.Connections) {
+		return
+	}
+	group := app.Connections[index].Connection.Group
+	for group != "" && group != "." {
+		delete(app.Collapsed, group)
+		group = path.Dir(group)
+	}
+	app.SelectedIndex = index
+	app.SelectedGroup = ""
+}
+
+// ToggleCollapsed collapses or expands the selected group
+func (app *AppState) ToggleCollapsed() {
+	if app.SelectedGroup == "" {
+		return
+	}
+	if app.Collapsed == nil {
+		app.Collapsed = make(map[string]bool)
+	}
+	app.Collapsed[app.SelectedGroup] = !app.Collapsed[app.SelectedGroup]
+}
+
+// CollapseSelected collapses the group the selection is in, selecting it
+// A collapsed group that is selected collapses its parent instead
+func (app *AppState) CollapseSelected() {
+	group := app.SelectedGroup
+	switch {
+	case group == "":
+		if selected := app.GetSelected(); selected != nil {
+			group = selected.Connection.Group
+		}
+	case app.Collapsed[group]:
+		if group = path.Dir(group); group == "." {
+			group = ""
+		}
+	}
+	if group == "" {
+		return
+	}
+	if app.Collapsed == nil {
+		app.Collapsed = make(map[string]bool)
+	}
+	app.Collapsed[group] = true
+	app.SelectedGroup = group
+}
+
+// ExpandSelected expands the selected group
+func (app *AppState) ExpandSelected() {
+	if app.SelectedGroup != "" {
+		delete(app.Collapsed, app.SelectedGroup)
+	}
+}
+
+// ToggleGroupMarked marks every connection in a group and its subgroups,
+// or unmarks them when they are all marked already
+func (app *AppState) ToggleGroupMarked(group string) {
+	all := true
+	for _, cs := range app.Connections {
+		if cs.Connection.InGroup(group) && !cs.Marked {
+			all = false
+		}
+	}
+	for _, cs := range app.Connections {
+		if cs.Connection.InGroup(group) {
+			cs.Marked = !all
+		}
+	}
+}
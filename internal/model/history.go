@@ -0,0 +1,270 @@
+package model
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+	"unicode/utf8"
+)
+
+// Retention defaults for the execution log
+const (
+	DefaultHistoryLimit  = 200      // Executions kept per connection
+	DefaultHistoryDays   = 30       // Days an execution is kept
+	DefaultHistoryOutput = 64 << 10 // Bytes of stdout and of stderr kept per execution
+)
+
+// HistoryEntry is one finished execution in a connection's log
+type HistoryEntry struct {
+	Command    string    `json:"command"`
+	Timestamp  time.Time `json:"timestamp"`
+	ExitCode   int       `json:"exit_code"`
+	DurationMS int64     `json:"duration_ms"`
+	Outcome    string    `json:"outcome,omitempty"` // Empty when the command exited on its own
+	TimeoutMS  int64     `json:"timeout_ms,omitempty"`
+	Stdout     string    `json:"stdout,omitempty"`
+	Stderr     string    `json:"stderr,omitempty"`
+	Truncated  bool      `json:"truncated,omitempty"` // Output was cut to its last DefaultHistoryOutput bytes
+}
+
+// maxHistoryLine bounds one line of the log, an entry escapes to well under it
+// even when all of its output is control bytes
+const maxHistoryLine = 16 * DefaultHistoryOutput
+
+// HistoryRetention bounds how much of the execution log is kept
+type HistoryRetention struct {
+	Limit  int           // Executions kept per connection, 0 disables the log
+	MaxAge time.Duration // Older executions are dropped, 0 keeps them regardless of age
+}
+
+// HistoryRetention returns the configured retention of the execution log
+// A negative history_limit turns the log off
+func (c *Config) HistoryRetention() HistoryRetention {
+	r := HistoryRetention{Limit: DefaultHistoryLimit, MaxAge: DefaultHistoryDays * 24 * time.Hour}
+	switch {
+	case c.HistoryLimit < 0:
+		r.Limit = 0
+	case c.HistoryLimit > 0:
+		r.Limit = c.HistoryLimit
+	}
+	switch {
+	case c.HistoryDays < 0:
+		r.MaxAge = 0
+	case c.HistoryDays > 0:
+		r.MaxAge = time.Duration(c.HistoryDays) * 24 * time.Hour
+	}
+	return r
+}
+
+// historyPath returns the log file of a connection, creating its directory
+func historyPath(alias string) (string, error) {
+	configPath, err := ConfigPath()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(filepath.Dir(configPath), "history")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create history directory: %w", err)
+	}
+	// Aliases are free text, keep them from escaping the directory
+	return filepath.Join(dir, url.PathEscape(alias)+".ndjson"), nil
+}
+
+// newHistoryEntry records a finished execution, keeping the tail of its output
+func newHistoryEntry(exec *CommandExecution) HistoryEntry {
+	entry := HistoryEntry{
+		Command:    exec.Command,
+		Timestamp:  exec.Timestamp,
+		ExitCode:   exec.ExitCode,
+		DurationMS: exec.Duration.Milliseconds(),
+		TimeoutMS:  exec.Timeout.Milliseconds(),
+	}
+	if exec.Outcome != OutcomeExited {
+		entry.Outcome = exec.Outcome.String()
+	}
+	var cutOut, cutErr bool
+	entry.Stdout, cutOut = tail(exec.Stdout, DefaultHistoryOutput)
+	entry.Stderr, cutErr = tail(exec.Stderr, DefaultHistoryOutput)
+	entry.Truncated = cutOut || cutErr
+	return entry
+}
+
+// tail returns at most the last limit bytes of s and whether anything was cut
+// The cut never splits a rune
+func tail(s string, limit int) (string, bool) {
+	if len(s) <= limit {
+		return s, false
+	}
+	start := len(s) - limit
+	for start < len(s) && !utf8.RuneStart(s[start]) {
+		start++
+	}
+	return s[start:], true
+}
+
+// execution turns a log entry back into a completed execution
+func (e HistoryEntry) execution() *CommandExecution {
+	exec := &CommandExecution{
+		Command:   e.Command,
+		Timestamp: e.Timestamp,
+		ExitCode:  e.ExitCode,
+		Duration:  time.Duration(e.DurationMS) * time.Millisecond,
+		Timeout:   time.Duration(e.TimeoutMS) * time.Millisecond,
+		Stdout:    e.Stdout,
+		Stderr:    e.Stderr,
+		Completed: true,
+	}
+	switch e.Outcome {
+	case OutcomeCancelled.String():
+		exec.Outcome = OutcomeCancelled
+	case OutcomeTimedOut.String():
+		exec.Outcome = OutcomeTimedOut
+	}
+	return exec
+}
+
+// AppendHistory adds a finished execution to the connection's log
+func AppendHistory(alias string, exec *CommandExecution) error {
+	path, err := historyPath(alias)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(newHistoryEntry(exec))
+	if err != nil {
+		return fmt.Errorf("failed to serialize execution: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	// A torn write leaves the last line unterminated, the entry must not join it
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			data = append([]byte{'\n'}, data...)
+		}
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return f.Close()
+}
+
+// LoadHistory reads the connection's log, oldest first, applying retention
+// The log is compacted on disk when entries were dropped
+func LoadHistory(alias string, retention HistoryRetention) ([]*CommandExecution, error) {
+	path, err := historyPath(alias)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+
+	var entries []HistoryEntry
+	dropped := false
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var entry HistoryEntry
+			if len(line) > maxHistoryLine || json.Unmarshal(line, &entry) != nil {
+				// A torn write from a crash or a damaged file, skip it
+				dropped = true
+			} else {
+				entries = append(entries, entry)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to read history: %w", err)
+		}
+	}
+	f.Close()
+
+	if retention.MaxAge > 0 {
+		cutoff := time.Now().Add(-retention.MaxAge)
+		kept := entries[:0]
+		for _, entry := range entries {
+			if entry.Timestamp.After(cutoff) {
+				kept = append(kept, entry)
+			}
+		}
+		dropped = dropped || len(kept) < len(entries)
+		entries = kept
+	}
+	if len(entries) > retention.Limit {
+		entries = entries[len(entries)-retention.Limit:]
+		dropped = true
+	}
+	if dropped {
+		if err := writeHistory(path, entries); err != nil {
+			return nil, err
+		}
+	}
+
+	executions := make([]*CommandExecution, 0, len(entries))
+	for _, entry := range entries {
+		executions = append(executions, entry.execution())
+	}
+	return executions, nil
+}
+
+// writeHistory replaces a log with the given entries
+func writeHistory(path string, entries []HistoryEntry) error {
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to serialize execution: %w", err)
+		}
+		buf.Write(append(data, '\n'))
+	}
+	if err := writeFileAtomic(path, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to compact history: %w", err)
+	}
+	return nil
+}
+
+// DeleteHistory removes the connection's log
+func DeleteHistory(alias string) error {
+	path, err := historyPath(alias)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete history: %w", err)
+	}
+	return nil
+}
+
+// RenameHistory moves the log of a connection whose alias changed
+func RenameHistory(oldAlias, newAlias string) error {
+	oldPath, err := historyPath(oldAlias)
+	if err != nil {
+		return err
+	}
+	newPath, err := historyPath(newAlias)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(oldPath, newPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to rename history: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package model
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// finished returns a completed execution of cmd that ran at the given time
+func finished(cmd string, at time.Time) *CommandExecution {
+	exec := NewCommandExecution(cmd)
+	exec.Timestamp = at
+	exec.AppendOutput(false, cmd+" output\n")
+	exec.Finish(0, time.Second, nil)
+	return exec
+}
+
+// appendAll logs executions of the given commands, one second apart
+func appendAll(t *testing.T, alias string, start time.Time, commands ...string) {
+	t.Helper()
+	for i, cmd := range commands {
+		if err := AppendHistory(alias, finished(cmd, start.Add(time.Duration(i)*time.Second))); err != nil {
+			t.Fatalf("AppendHistory: %v", err)
+		}
+	}
+}
+
+// loadCommands loads a log and returns the commands in it
+func loadCommands(t *testing.T, alias string, retention HistoryRetention) []string {
+	t.Helper()
+	executions, err := LoadHistory(alias, retention)
+	if err != nil {
+		t.Fatalf("LoadHistory: %v", err)
+	}
+	commands := []string{}
+	for _, exec := range executions {
+		commands = append(commands, exec.Command)
+	}
+	return commands
+}
+
+// appendRaw appends bytes to a log as is
+func appendRaw(t *testing.T, alias string, data string) {
+	t.Helper()
+	path, err := historyPath(alias)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// historyLines returns the lines of a log on disk
+func historyLines(t *testing.T, alias string) []string {
+	t.Helper()
+	path, err := historyPath(alias)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestHistoryRetention(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   HistoryRetention
+	}{
+		{name: "defaults", want: HistoryRetention{Limit: DefaultHistoryLimit, MaxAge: DefaultHistoryDays * 24 * time.Hour}},
+		{name: "configured", config: Config{HistoryLimit: 5, HistoryDays: 2}, want: HistoryRetention{Limit: 5, MaxAge: 48 * time.Hour}},
+		{name: "disabled", config: Config{HistoryLimit: -1}, want: HistoryRetention{Limit: 0, MaxAge: DefaultHistoryDays * 24 * time.Hour}},
+		{name: "no age limit", config: Config{HistoryDays: -1}, want: HistoryRetention{Limit: DefaultHistoryLimit}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.HistoryRetention(); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadHistoryPrunesByCount(t *testing.T) {
+	useConfigDir(t)
+	appendAll(t, "web", time.Now().Add(-time.Hour), "one", "two", "three", "four", "five")
+
+	got := loadCommands(t, "web", HistoryRetention{Limit: 3})
+	if want := []string{"three", "four", "five"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded %q, want %q", got, want)
+	}
+	if lines := historyLines(t, "web"); len(lines) != 3 {
+		t.Errorf("log holds %d lines after compaction, want 3", len(lines))
+	}
+}
+
+func TestLoadHistoryPrunesByAge(t *testing.T) {
+	useConfigDir(t)
+	now := time.Now()
+	appendAll(t, "web", now.Add(-72*time.Hour), "old")
+	appendAll(t, "web", now.Add(-time.Hour), "recent", "newest")
+
+	if got, want := loadCommands(t, "web", HistoryRetention{Limit: 10}), []string{"old", "recent", "newest"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("without an age limit loaded %q, want %q", got, want)
+	}
+	got := loadCommands(t, "web", HistoryRetention{Limit: 10, MaxAge: 24 * time.Hour})
+	if want := []string{"recent", "newest"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded %q, want %q", got, want)
+	}
+	if lines := historyLines(t, "web"); len(lines) != 2 {
+		t.Errorf("log holds %d lines after compaction, want 2", len(lines))
+	}
+}
+
+func TestLoadHistoryLeavesCleanLogAlone(t *testing.T) {
+	useConfigDir(t)
+	appendAll(t, "web", time.Now(), "one", "two")
+	path, err := historyPath("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loadCommands(t, "web", HistoryRetention{Limit: 10, MaxAge: time.Hour})
+	if after, err := os.Stat(path); err != nil || !os.SameFile(before, after) {
+		t.Error("log was rewritten although nothing was dropped")
+	}
+}
+
+func TestLoadHistorySkipsDamagedLines(t *testing.T) {
+	useConfigDir(t)
+	appendAll(t, "web", time.Now(), "before")
+	appendRaw(t, "web", strings.Repeat("x", maxHistoryLine+1)+"\n")
+	appendRaw(t, "web", "not json\n\n")
+	appendAll(t, "web", time.Now(), "after")
+
+	got := loadCommands(t, "web", HistoryRetention{Limit: 10})
+	if want := []string{"before", "after"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded %q, want %q", got, want)
+	}
+	if lines := historyLines(t, "web"); len(lines) != 2 {
+		t.Errorf("log holds %d lines after compaction, want 2", len(lines))
+	}
+}
+
+func TestLoadHistoryTruncatedLastLine(t *testing.T) {
+	useConfigDir(t)
+	appendAll(t, "web", time.Now(), "one", "two")
+	// A crash mid-write leaves part of an entry without its newline
+	appendRaw(t, "web", `{"command":"thr`)
+
+	if got, want := loadCommands(t, "web", HistoryRetention{Limit: 10}), []string{"one", "two"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded %q, want %q", got, want)
+	}
+
+	// Entries logged after a torn write must not be glued to it
+	appendRaw(t, "web", `{"command":"fou`)
+	appendAll(t, "web", time.Now(), "five")
+	if got, want := loadCommands(t, "web", HistoryRetention{Limit: 10}), []string{"one", "two", "five"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded %q, want %q", got, want)
+	}
+}
+
+func TestLoadHistoryMissing(t *testing.T) {
+	useConfigDir(t)
+	executions, err := LoadHistory("nothing yet", HistoryRetention{Limit: 10})
+	if err != nil || executions != nil {
+		t.Errorf("LoadHistory = %v, %v, want nothing", executions, err)
+	}
+}
+
+func TestHistoryRoundTrip(t *testing.T) {
+	useConfigDir(t)
+	at := time.Now().Add(-time.Minute).Round(time.Millisecond)
+
+	exec := NewCommandExecution("sleep 100")
+	exec.Timestamp = at
+	exec.Timeout = 5 * time.Second
+	exec.AppendOutput(false, "out\n")
+	exec.AppendOutput(true, "err\n")
+	exec.Finish(-1, 5*time.Second, context.DeadlineExceeded)
+	if err := AppendHistory("web", exec); err != nil {
+		t.Fatal(err)
+	}
+
+	executions, err := LoadHistory("web", HistoryRetention{Limit: 10})
+	if err != nil || len(executions) != 1 {
+		t.Fatalf("LoadHistory = %v, %v", executions, err)
+	}
+	got := executions[0]
+	if got.Command != "sleep 100" || !got.Timestamp.Equal(at) || got.Outcome != OutcomeTimedOut ||
+		got.Timeout != 5*time.Second || got.Duration != 5*time.Second || got.ExitCode != -1 ||
+		got.Stdout != "out\n" || got.Stderr != "err\n" || !got.Completed {
+		t.Errorf("loaded %+v", got)
+	}
+}
+
+func TestNewHistoryEntryKeepsOutputTail(t *testing.T) {
+	exec := NewCommandExecution("cat big")
+	// Multi-byte runes, so a cut at the byte limit lands mid-rune
+	big := "x" + strings.Repeat("é", DefaultHistoryOutput)
+	exec.AppendOutput(false, big)
+	exec.Finish(0, time.Second, nil)
+
+	entry := newHistoryEntry(exec)
+	if !entry.Truncated {
+		t.Error("entry not marked truncated")
+	}
+	if len(entry.Stdout) > DefaultHistoryOutput || !utf8.ValidString(entry.Stdout) ||
+		!strings.HasSuffix(big, entry.Stdout) {
+		t.Errorf("kept %d bytes, valid UTF-8 %v", len(entry.Stdout), utf8.ValidString(entry.Stdout))
+	}
+
+	small := newHistoryEntry(finished("ls", time.Now()))
+	if small.Truncated || small.Stdout != "ls output\n" {
+		t.Errorf("small output kept as %q, truncated %v", small.Stdout, small.Truncated)
+	}
+}
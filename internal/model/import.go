@@ -0,0 +1,170 @@
+package model
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/SimonLariz/beacon/internal/ssh"
+)
+
+// ImportResult summarizes what an import did to the configuration
+type ImportResult struct {
+	Added   []*Connection // New connections
+	Merged  []*Connection // Existing connections that had empty fields filled in
+	Skipped []string      // Aliases that were already fully present
+}
+
+// String returns a one-line summary for status messages
+func (r *ImportResult) String() string {
+	return fmt.Sprintf("%d added, %d merged, %d skipped", len(r.Added), len(r.Merged), len(r.Skipped))
+}
+
+// ConnectionsFromSSHConfig builds a connection for every concrete Host entry in ~/.ssh/config
+func ConnectionsFromSSHConfig() ([]*Connection, error) {
+	hosts, err := ssh.ConfigHosts()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ssh config: %w", err)
+	}
+
+	connections := make([]*Connection, 0, len(hosts))
+	for _, host := range hosts {
+		// Connecting by the alias lets ssh_config apply HostName and every other
+		// setting keyed on it, the resolved HostName would no longer match them
+		conn := NewConnection(host.Host, host.Host, host.User, host.Port)
+		conn.hostName = host.HostName
+		if host.ProxyJump != "" && !strings.EqualFold(host.ProxyJump, "none") {
+			// Hops that are imported aliases resolve to those connections later
+			for _, hop := range strings.Split(host.ProxyJump, ",") {
+				conn.Jumps = append(conn.Jumps, strings.TrimSpace(hop))
+			}
+		}
+		// Only keep a key that actually exists, ssh config defaults often don't
+		for _, path := range host.IdentityFiles {
+			if _, err := os.Stat(path); err == nil {
+				conn.KeyPath = path
+				break
+			}
+		}
+		connections = append(connections, conn)
+	}
+	return connections, nil
+}
+
+// MergeConnections adds imported connections to the config
+// An existing alias is merged by filling its empty fields, nothing is overwritten
+// A connection to the same target under another alias is skipped
+func (c *Config) MergeConnections(imported []*Connection) *ImportResult {
+	result := &ImportResult{}
+
+	// Imported hosts are ssh_config aliases, existing connections may use them too
+	aliases := make(map[string]*Connection)
+	for _, conn := range imported {
+		aliases[conn.Alias] = conn
+	}
+
+	for _, conn := range imported {
+		if existing := c.FindConnection(conn.Alias); existing != nil {
+			if mergeConnection(existing, conn) {
+				result.Merged = append(result.Merged, existing)
+			} else {
+				result.Skipped = append(result.Skipped, conn.Alias)
+			}
+			continue
+		}
+
+		if c.hasTarget(conn, aliases) {
+			result.Skipped = append(result.Skipped, conn.Alias)
+			continue
+		}
+
+		c.Connections = append(c.Connections, conn)
+		result.Added = append(result.Added, conn)
+	}
+	return result
+}
+
+// FindConnection returns the connection with the given alias, or nil
+func (c *Config) FindConnection(alias string) *Connection {
+	for _, conn := range c.Connections {
+		if conn.Alias == alias {
+			return conn
+		}
+	}
+	return nil
+}
+
+// hasTarget reports whether a connection to the same user@host:port via the same jumps exists
+// Hosts are compared once resolved, so a connection added by address matches
+// an ssh_config alias for that address
+func (c *Config) hasTarget(target *Connection, aliases map[string]*Connection) bool {
+	host, user, port := target.resolvedTarget(aliases)
+	for _, conn := range c.Connections {
+		h, u, p := conn.resolvedTarget(aliases)
+		if strings.EqualFold(h, host) && u == user && p == port &&
+			strings.Join(conn.Jumps, ",") == strings.Join(target.Jumps, ",") {
+			return true
+		}
+	}
+	return false
+}
+
+// resolvedTarget returns the host, user and port a connection ends up at
+// A host naming one of the imported aliases takes its HostName, and the
+// User and Port the connection leaves empty
+func (c *Connection) resolvedTarget(aliases map[string]*Connection) (string, string, int) {
+	host, user, port := c.Host, c.User, c.Port
+	if alias, ok := aliases[host]; ok && alias.hostName != "" {
+		host = alias.hostName
+		if user == "" {
+			user = alias.User
+		}
+		if port == 0 {
+			port = alias.Port
+		}
+	}
+	if port == 0 {
+		port = 22
+	}
+	return host, user, port
+}
+
+// mergeConnection fills empty fields of dst from src, returns true if anything changed
+func mergeConnection(dst, src *Connection) bool {
+	changed := false
+	if dst.Host == "" && src.Host != "" {
+		dst.Host = src.Host
+		changed = true
+	}
+	if dst.User == "" && src.User != "" {
+		dst.User = src.User
+		changed = true
+	}
+	if dst.Port == 0 && src.Port != 0 {
+		dst.Port = src.Port
+		changed = true
+	}
+	if dst.KeyPath == "" && src.KeyPath != "" {
+		dst.KeyPath = src.KeyPath
+		changed = true
+	}
+	if len(dst.Jumps) == 0 && len(src.Jumps) > 0 {
+		dst.Jumps = src.Jumps
+		changed = true
+	}
+	return changed
+}
+
+// ImportConnections merges imported connections and tracks state for the new ones
+func (app *AppState) ImportConnections(imported []*Connection) *ImportResult {
+	result := app.Config.MergeConnections(imported)
+	for _, conn := range result.Added {
+		app.Connections = append(app.Connections, &ConnectionState{
+			Connection: conn,
+			Status:     StatusDisconnected,
+			Output:     make([]string, 0),
+			Executions: make([]*CommandExecution, 0),
+		})
+	}
+	return result
+}
@@ -0,0 +1,174 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// imported returns a connection the way ConnectionsFromSSHConfig builds it
+func imported(alias, hostName, user string, port int) *Connection {
+	conn := NewConnection(alias, alias, user, port)
+	conn.hostName = hostName
+	return conn
+}
+
+func TestMergeConnections(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []*Connection
+		imported []*Connection
+		added    []string
+		merged   []string
+		skipped  []string
+	}{
+		{
+			name:     "new host",
+			imported: []*Connection{imported("db", "10.0.0.5", "admin", 0)},
+			added:    []string{"db"},
+		},
+		{
+			name:     "address added by hand",
+			existing: []*Connection{NewConnection("database", "10.0.0.5", "admin", 22)},
+			imported: []*Connection{imported("db", "10.0.0.5", "admin", 0)},
+			skipped:  []string{"db"},
+		},
+		{
+			name:     "host names compare case-insensitively",
+			existing: []*Connection{NewConnection("web", "Web.Example.com", "deploy", 0)},
+			imported: []*Connection{imported("www", "web.example.com", "deploy", 22)},
+			skipped:  []string{"www"},
+		},
+		{
+			name:     "same address as another user",
+			existing: []*Connection{NewConnection("database", "10.0.0.5", "root", 22)},
+			imported: []*Connection{imported("db", "10.0.0.5", "admin", 0)},
+			added:    []string{"db"},
+		},
+		{
+			name:     "same address on another port",
+			existing: []*Connection{NewConnection("database", "10.0.0.5", "admin", 2222)},
+			imported: []*Connection{imported("db", "10.0.0.5", "admin", 0)},
+			added:    []string{"db"},
+		},
+		{
+			name:     "same address through a jump host",
+			existing: []*Connection{NewConnection("database", "10.0.0.5", "admin", 22)},
+			imported: []*Connection{func() *Connection {
+				conn := imported("db", "10.0.0.5", "admin", 0)
+				conn.Jumps = []string{"bastion"}
+				return conn
+			}()},
+			added: []string{"db"},
+		},
+		{
+			name:     "renamed import of the same alias",
+			existing: []*Connection{NewConnection("database", "db", "", 0)},
+			imported: []*Connection{imported("db", "10.0.0.5", "admin", 0)},
+			skipped:  []string{"db"},
+		},
+		{
+			name:     "existing alias gets empty fields filled",
+			existing: []*Connection{NewConnection("db", "db", "", 0)},
+			imported: []*Connection{imported("db", "10.0.0.5", "admin", 2222)},
+			merged:   []string{"db"},
+		},
+		{
+			name:     "existing alias already complete",
+			existing: []*Connection{NewConnection("db", "db", "admin", 2222)},
+			imported: []*Connection{imported("db", "10.0.0.5", "admin", 2222)},
+			skipped:  []string{"db"},
+		},
+		{
+			name: "two aliases for one address",
+			imported: []*Connection{
+				imported("db", "10.0.0.5", "admin", 0),
+				imported("db-alt", "10.0.0.5", "admin", 22),
+			},
+			added:   []string{"db"},
+			skipped: []string{"db-alt"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Connections: tt.existing}
+			result := config.MergeConnections(tt.imported)
+			if got := aliases(result.Added); !reflect.DeepEqual(got, nonNil(tt.added)) {
+				t.Errorf("added %q, want %q", got, tt.added)
+			}
+			if got := aliases(result.Merged); !reflect.DeepEqual(got, nonNil(tt.merged)) {
+				t.Errorf("merged %q, want %q", got, tt.merged)
+			}
+			if !reflect.DeepEqual(result.Skipped, tt.skipped) {
+				t.Errorf("skipped %q, want %q", result.Skipped, tt.skipped)
+			}
+		})
+	}
+}
+
+// nonNil returns list, or an empty list for nil
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
+
+func TestConnectionsFromSSHConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	sshDir := filepath.Join(home, ".ssh")
+	if err := os.MkdirAll(sshDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	config := `
+Host db
+  HostName 10.0.0.5
+  User admin
+  ProxyJump bastion
+
+Host web
+  Port 2222
+
+Host *.internal !skip
+  User nobody
+`
+	if err := os.WriteFile(filepath.Join(sshDir, "config"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	connections, err := ConnectionsFromSSHConfig()
+	if err != nil {
+		t.Fatalf("ConnectionsFromSSHConfig: %v", err)
+	}
+	if got, want := aliases(connections), []string{"db", "web"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("aliases %q, want %q", got, want)
+	}
+
+	db := connections[0]
+	if db.Host != "db" || db.hostName != "10.0.0.5" || db.User != "admin" ||
+		!reflect.DeepEqual(db.Jumps, []string{"bastion"}) {
+		t.Errorf("db imported as %+v", db)
+	}
+	if web := connections[1]; web.Host != "web" || web.hostName != "web" || web.Port != 2222 {
+		t.Errorf("web imported as %+v", web)
+	}
+
+	// The address is already there, added by hand
+	existing := &Config{Connections: []*Connection{
+		func() *Connection {
+			conn := NewConnection("database", "10.0.0.5", "admin", 0)
+			conn.Jumps = []string{"bastion"}
+			return conn
+		}(),
+	}}
+	result := existing.MergeConnections(connections)
+	if got := aliases(result.Added); !reflect.DeepEqual(got, []string{"web"}) {
+		t.Errorf("added %q, want [web]", got)
+	}
+	if !reflect.DeepEqual(result.Skipped, []string{"db"}) {
+		t.Errorf("skipped %q, want [db]", result.Skipped)
+	}
+}
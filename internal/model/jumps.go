@@ -0,0 +1,111 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/SimonLariz/beacon/internal/ssh"
+)
+
+// ResolveJumps expands a connection's jump chain into hops for the ssh layer
+// Entries naming another connection use that connection's settings (and its
+// own jump chain, hops first), anything else is parsed as [user@]host[:port]
+func (c *Config) ResolveJumps(conn *Connection) ([]ssh.JumpHost, error) {
+	return c.resolveJumps(conn, map[string]bool{conn.Alias: true})
+}
+
+// resolveJumps does the work for ResolveJumps, visiting guards against cycles
+func (c *Config) resolveJumps(conn *Connection, visiting map[string]bool) ([]ssh.JumpHost, error) {
+	var hops []ssh.JumpHost
+	for _, entry := range conn.Jumps {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		ref := c.FindConnection(entry)
+		if ref == nil {
+			hop, err := ssh.ParseJumpSpec(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid jump host %q for %s: %v", entry, conn.Alias, err)
+			}
+			// Inline hops share the connection's known_hosts override
+			hop.KnownHostsFile = conn.KnownHostsFile
+			hops = append(hops, hop)
+			continue
+		}
+
+		if visiting[ref.Alias] {
+			return nil, fmt.Errorf("jump chain for %s loops back to %s", conn.Alias, ref.Alias)
+		}
+		visiting[ref.Alias] = true
+		refHops, err := c.resolveJumps(ref, visiting)
+		delete(visiting, ref.Alias)
+		if err != nil {
+			return nil, err
+		}
+
+		hops = append(hops, refHops...)
+		hops = append(hops, ref.JumpHost())
+	}
+	return hops, nil
+}
+
+// JumpHost returns the connection as a hop for another connection's chain
+func (c *Connection) JumpHost() ssh.JumpHost {
+	hop := ssh.JumpHost{
+		Host:           c.Host,
+		Port:           c.Port,
+		User:           c.User,
+		KeyPath:        c.KeyPath,
+		KnownHostsFile: c.KnownHostsFile,
+	}
+	if !c.NeverSavePassword {
+		hop.Password = c.Password
+	}
+	return hop
+}
+
+// HopPath returns the expanded jump chain for display, e.g. "bastion → db-jump"
+// Returns an empty string for direct connections
+func (c *Config) HopPath(conn *Connection) string {
+	if len(conn.Jumps) == 0 {
+		return ""
+	}
+	names, err := c.hopNames(conn, map[string]bool{conn.Alias: true})
+	if err != nil {
+		return fmt.Sprintf("invalid jump chain: %v", err)
+	}
+	return strings.Join(names, " → ")
+}
+
+// hopNames lists the hops by alias or spec, following referenced connections
+func (c *Config) hopNames(conn *Connection, visiting map[string]bool) ([]string, error) {
+	var names []string
+	for _, entry := range conn.Jumps {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		ref := c.FindConnection(entry)
+		if ref == nil {
+			names = append(names, entry)
+			continue
+		}
+
+		if visiting[ref.Alias] {
+			return nil, fmt.Errorf("loop at %s", ref.Alias)
+		}
+		visiting[ref.Alias] = true
+		refNames, err := c.hopNames(ref, visiting)
+		delete(visiting, ref.Alias)
+		if err != nil {
+			return nil, err
+		}
+
+		names = append(names, refNames...)
+		names = append(names, ref.Alias)
+	}
+	return names, nil
+}
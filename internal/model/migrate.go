@@ -0,0 +1,82 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ConfigVersion is the schema version of connections.json this build writes
+// Files without a version predate versioning and count as version 0
+const ConfigVersion = 1
+
+// ErrConfigTooNew is returned for a config written by a newer beacon
+// Loading it would drop the fields this build doesn't know about on the next save
+var ErrConfigTooNew = errors.New("config file was written by a newer version of beacon")
+
+// configMigration upgrades a decoded config by one schema version
+type configMigration func(fields map[string]json.RawMessage) error
+
+// configMigrations[v] upgrades a config from version v to v+1
+var configMigrations = []configMigration{
+	migrateUnversioned,
+}
+
+// migrateUnversioned upgrades a config from before versioning, where the
+// connection list and command history may be missing or null
+func migrateUnversioned(fields map[string]json.RawMessage) error {
+	for _, key := range []string{"connections", "command_history"} {
+		if raw, ok := fields[key]; !ok || string(raw) == "null" {
+			fields[key] = json.RawMessage("[]")
+		}
+	}
+	return nil
+}
+
+// migrateConfig upgrades config file contents to ConfigVersion
+// Returns the upgraded contents and the version the file had
+func migrateConfig(data []byte) ([]byte, int, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, 0, err
+	}
+
+	version := 0
+	if raw, ok := fields["version"]; ok {
+		// null would decode as 0 without an error
+		if err := json.Unmarshal(raw, &version); err != nil || version < 0 || string(raw) == "null" {
+			return nil, 0, fmt.Errorf("invalid schema version %s", raw)
+		}
+	}
+	if version > ConfigVersion {
+		return nil, version, fmt.Errorf("%w (schema version %d, this build supports up to %d), upgrade beacon to use it",
+			ErrConfigTooNew, version, ConfigVersion)
+	}
+	if version == ConfigVersion {
+		return data, version, nil
+	}
+
+	for v := version; v < ConfigVersion; v++ {
+		if err := configMigrations[v](fields); err != nil {
+			return nil, version, fmt.Errorf("failed to migrate config from version %d: %w", v, err)
+		}
+	}
+	fields["version"] = json.RawMessage(fmt.Sprint(ConfigVersion))
+
+	migrated, err := json.Marshal(fields)
+	if err != nil {
+		return nil, version, err
+	}
+	return migrated, version, nil
+}
+
+// backupConfig copies the config file aside before a migration rewrites it
+func backupConfig(configPath string, data []byte, version int) error {
+	backup := fmt.Sprintf("%s.v%d-%s.bak", configPath, version, time.Now().Format("20060102-150405"))
+	if err := os.WriteFile(backup, data, 0600); err != nil {
+		return fmt.Errorf("failed to back up config file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMigrateConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    string
+		version int
+	}{
+		{
+			name:    "unversioned without lists",
+			data:    `{}`,
+			want:    `{"command_history":[],"connections":[],"version":1}`,
+			version: 0,
+		},
+		{
+			name:    "unversioned with null lists",
+			data:    `{"connections":null,"command_history":null}`,
+			want:    `{"command_history":[],"connections":[],"version":1}`,
+			version: 0,
+		},
+		{
+			name:    "unversioned keeps its data",
+			data:    `{"connections":[{"alias":"web","host":"web"}],"command_history":["ls"],"future":true}`,
+			want:    `{"command_history":["ls"],"connections":[{"alias":"web","host":"web"}],"future":true,"version":1}`,
+			version: 0,
+		},
+		{
+			name:    "explicit version 0",
+			data:    `{"version":0,"connections":[]}`,
+			want:    `{"command_history":[],"connections":[],"version":1}`,
+			version: 0,
+		},
+		{
+			name:    "current version is untouched",
+			data:    "{\n  \"version\": 1,\n  \"connections\": null\n}",
+			want:    "{\n  \"version\": 1,\n  \"connections\": null\n}",
+			version: ConfigVersion,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, version, err := migrateConfig([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("migrateConfig: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+			if version != tt.version {
+				t.Errorf("version %d, want %d", version, tt.version)
+			}
+		})
+	}
+}
+
+func TestMigrateConfigRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		tooNew  bool
+		version int
+	}{
+		{name: "newer version", data: `{"version":2}`, tooNew: true, version: 2},
+		{name: "negative version", data: `{"version":-1}`},
+		{name: "fractional version", data: `{"version":1.5}`},
+		{name: "string version", data: `{"version":"1"}`},
+		{name: "null version", data: `{"version":null,"connections":[]}`},
+		{name: "not an object", data: `[]`},
+		{name: "not JSON", data: `{"connections":`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, version, err := migrateConfig([]byte(tt.data))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if errors.Is(err, ErrConfigTooNew) != tt.tooNew {
+				t.Errorf("error %v, too new %v", err, tt.tooNew)
+			}
+			if version != tt.version {
+				t.Errorf("version %d, want %d", version, tt.version)
+			}
+		})
+	}
+}
+
+// backups returns the backup files next to the config
+func backups(t *testing.T, configPath string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(configPath + ".v*.bak")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return matches
+}
+
+func TestLoadConfigMigrates(t *testing.T) {
+	path := useConfigDir(t)
+	original := []byte(`{"connections":[{"alias":"web","host":"web.example.com","port":22,"user":"deploy"}],"command_history":null}`)
+	if err := os.WriteFile(path, original, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config := loadConfig(t)
+	if config.Version != ConfigVersion || len(config.Connections) != 1 || config.Connections[0].Alias != "web" {
+		t.Errorf("loaded %+v", config)
+	}
+
+	found := backups(t, path)
+	if len(found) != 1 {
+		t.Fatalf("backups %q, want one", found)
+	}
+	if filepath.Base(found[0])[:len("connections.json.v0-")] != "connections.json.v0-" {
+		t.Errorf("backup %s is not named after version 0", found[0])
+	}
+	if backup, err := os.ReadFile(found[0]); err != nil || string(backup) != string(original) {
+		t.Errorf("backup holds %s, %v, want the original", backup, err)
+	}
+
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(saved, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if string(fields["version"]) != "1" {
+		t.Errorf("rewritten config has version %s", fields["version"])
+	}
+}
+
+func TestLoadConfigBacksUpBeforeRewriting(t *testing.T) {
+	path := useConfigDir(t)
+	original := []byte(`{"connections":[]}`)
+	if err := os.WriteFile(path, original, 0600); err != nil {
+		t.Fatal(err)
+	}
+	// Directories in the way of the backups make them fail
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		backup := path + ".v0-" + now.Add(time.Duration(i)*time.Second).Format("20060102-150405") + ".bak"
+		if err := os.Mkdir(backup, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := LoadConfig(); err == nil {
+		t.Fatal("LoadConfig succeeded without a backup")
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != string(original) {
+		t.Errorf("config was rewritten to %s, %v", data, err)
+	}
+}
+
+func TestLoadConfigCurrentVersion(t *testing.T) {
+	path := useConfigDir(t)
+	original := []byte("{\n  \"version\": 1,\n  \"connections\": [],\n  \"command_timeout\": 30\n}\n")
+	if err := os.WriteFile(path, original, 0600); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config := loadConfig(t); config.CommandTimeout != 30 {
+		t.Errorf("command timeout %d, want 30", config.CommandTimeout)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != string(original) {
+		t.Errorf("config changed to %s, %v", data, err)
+	}
+	if after, err := os.Stat(path); err != nil || !os.SameFile(before, after) {
+		t.Error("config file was replaced")
+	}
+	if found := backups(t, path); len(found) != 0 {
+		t.Errorf("unexpected backups %q", found)
+	}
+}
+
+func TestLoadConfigTooNew(t *testing.T) {
+	path := useConfigDir(t)
+	original := []byte(`{"version":99,"connections":[],"something_new":{}}`)
+	if err := os.WriteFile(path, original, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadConfig(); !errors.Is(err, ErrConfigTooNew) {
+		t.Fatalf("LoadConfig: %v, want ErrConfigTooNew", err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != string(original) {
+		t.Errorf("config changed to %s, %v", data, err)
+	}
+}
@@ -0,0 +1,205 @@
+package model
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultOutputLimit caps how much output is kept in memory per command
+const DefaultOutputLimit = 1 << 20 // 1 MiB
+
+// OutputChunk is a piece of command output as it arrived
+type OutputChunk struct {
+	Stderr bool
+	Data   string
+}
+
+// OutputFragment is the part of a line that came from a single stream
+type OutputFragment struct {
+	Stderr bool
+	Text   string
+}
+
+// OutputBuffer is a ring buffer holding the most recent output of a command
+// Stdout and stderr share one buffer so their interleaving order is kept
+// Chunks are kept as they arrived and the split lines are extended as output
+// streams in, so appending and rendering don't copy what is already held
+type OutputBuffer struct {
+	chunks  []OutputChunk // Ring storage, valid entries start at head
+	head    int           // Index of the oldest chunk
+	count   int           // Number of valid chunks
+	size    int           // Bytes held
+	limit   int           // Maximum bytes held
+	dropped int           // Bytes discarded to stay under limit
+
+	lines     [][]OutputFragment // Buffered output split into lines
+	lineSizes []int              // Bytes of each line, counting its newline
+	open      bool               // The last line has no newline yet
+}
+
+// NewOutputBuffer creates a buffer that keeps at most limit bytes
+func NewOutputBuffer(limit int) *OutputBuffer {
+	if limit <= 0 {
+		limit = DefaultOutputLimit
+	}
+	return &OutputBuffer{
+		chunks: make([]OutputChunk, 16),
+		limit:  limit,
+	}
+}
+
+// Append adds output, discarding the oldest bytes once the limit is reached
+func (b *OutputBuffer) Append(stderr bool, data string) {
+	if data == "" {
+		return
+	}
+	// A single chunk larger than the buffer only keeps its tail
+	if len(data) > b.limit {
+		cut := len(data) - b.limit
+		for i := 0; i < utf8.UTFMax-1 && cut < len(data) && !utf8.RuneStart(data[cut]); i++ {
+			cut++
+		}
+		// Everything held is older than the part cut off
+		b.trim(b.size)
+		b.dropped += cut
+		data = data[cut:]
+		if data == "" {
+			return
+		}
+	}
+
+	if b.count == len(b.chunks) {
+		b.grow()
+	}
+	b.chunks[(b.head+b.count)%len(b.chunks)] = OutputChunk{Stderr: stderr, Data: data}
+	b.count++
+	b.size += len(data)
+	b.appendLines(stderr, data)
+	if b.size > b.limit {
+		b.trim(b.size - b.limit)
+	}
+}
+
+// appendLines extends the split lines with a new chunk
+func (b *OutputBuffer) appendLines(stderr bool, data string) {
+	for data != "" {
+		part, rest, newline := strings.Cut(data, "\n")
+		if !b.open {
+			b.lines = append(b.lines, nil)
+			b.lineSizes = append(b.lineSizes, 0)
+			b.open = true
+		}
+		last := len(b.lines) - 1
+		if part != "" {
+			b.lines[last] = append(b.lines[last], OutputFragment{Stderr: stderr, Text: part})
+		}
+		b.lineSizes[last] += len(part)
+		if newline {
+			b.lineSizes[last]++
+			b.open = false
+		}
+		data = rest
+	}
+}
+
+// grow doubles the ring storage, unrolling it so head is at 0
+func (b *OutputBuffer) grow() {
+	grown := make([]OutputChunk, len(b.chunks)*2)
+	for i := 0; i < b.count; i++ {
+		grown[i] = b.chunks[(b.head+i)%len(b.chunks)]
+	}
+	b.chunks = grown
+	b.head = 0
+}
+
+// trim drops at least the oldest excess bytes
+// The cut moves on to the next rune boundary so the first line doesn't start
+// with half a UTF-8 sequence, by at most a few bytes in case the output isn't text
+func (b *OutputBuffer) trim(excess int) {
+	removed, skipped := 0, 0
+	for b.count > 0 {
+		oldest := &b.chunks[b.head]
+		cut := min(excess, len(oldest.Data))
+		for cut < len(oldest.Data) && skipped < utf8.UTFMax-1 && !utf8.RuneStart(oldest.Data[cut]) {
+			cut++
+			skipped++
+		}
+		if cut == 0 {
+			break
+		}
+		removed += cut
+		excess -= min(excess, cut)
+		if cut < len(oldest.Data) {
+			oldest.Data = oldest.Data[cut:]
+			break
+		}
+
+		*oldest = OutputChunk{}
+		b.head = (b.head + 1) % len(b.chunks)
+		b.count--
+	}
+	b.size -= removed
+	b.dropped += removed
+	b.trimLines(removed)
+}
+
+// trimLines drops the first excess bytes from the split lines
+func (b *OutputBuffer) trimLines(excess int) {
+	for excess > 0 && len(b.lines) > 0 {
+		if excess >= b.lineSizes[0] {
+			excess -= b.lineSizes[0]
+			b.lines[0] = nil
+			b.lines, b.lineSizes = b.lines[1:], b.lineSizes[1:]
+			if len(b.lines) == 0 {
+				b.open = false
+			}
+			continue
+		}
+
+		// Cut into the first line, which keeps its newline
+		b.lineSizes[0] -= excess
+		fragments := b.lines[0]
+		for excess > 0 && len(fragments) > 0 {
+			if excess < len(fragments[0].Text) {
+				fragments[0].Text = fragments[0].Text[excess:]
+				break
+			}
+			excess -= len(fragments[0].Text)
+			fragments = fragments[1:]
+		}
+		b.lines[0] = fragments
+		return
+	}
+}
+
+// Chunks returns the buffered output in arrival order
+func (b *OutputBuffer) Chunks() []OutputChunk {
+	chunks := make([]OutputChunk, 0, b.count)
+	for i := 0; i < b.count; i++ {
+		chunks = append(chunks, b.chunks[(b.head+i)%len(b.chunks)])
+	}
+	return chunks
+}
+
+// Stream returns everything buffered from stdout (or stderr)
+func (b *OutputBuffer) Stream(stderr bool) string {
+	var sb strings.Builder
+	for _, chunk := range b.Chunks() {
+		if chunk.Stderr == stderr {
+			sb.WriteString(chunk.Data)
+		}
+	}
+	return sb.String()
+}
+
+// Dropped returns how many bytes were discarded to stay under the limit
+func (b *OutputBuffer) Dropped() int {
+	return b.dropped
+}
+
+// Lines returns the buffered output split into lines, keeping which stream
+// each part came from, a trailing newline doesn't produce an empty last line
+// The result is shared with the buffer and must not be modified
+func (b *OutputBuffer) Lines() [][]OutputFragment {
+	return b.lines
+}
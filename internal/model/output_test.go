@@ -0,0 +1,219 @@
+package model
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// referenceLines splits output the way OutputBuffer.Lines does, from scratch
+func referenceLines(chunks []OutputChunk) [][]OutputFragment {
+	var lines [][]OutputFragment
+	open := false
+	for _, chunk := range chunks {
+		for data := chunk.Data; data != ""; {
+			part, rest, newline := strings.Cut(data, "\n")
+			if !open {
+				lines = append(lines, nil)
+				open = true
+			}
+			if part != "" {
+				last := len(lines) - 1
+				lines[last] = append(lines[last], OutputFragment{Stderr: chunk.Stderr, Text: part})
+			}
+			if newline {
+				open = false
+			}
+			data = rest
+		}
+	}
+	return lines
+}
+
+// heldBytes returns the last n bytes of chunks, keeping the stream of each
+func heldBytes(chunks []OutputChunk, n int) []OutputChunk {
+	var held []OutputChunk
+	for i := len(chunks) - 1; i >= 0 && n > 0; i-- {
+		chunk := chunks[i]
+		if len(chunk.Data) > n {
+			chunk.Data = chunk.Data[len(chunk.Data)-n:]
+		}
+		n -= len(chunk.Data)
+		held = append([]OutputChunk{chunk}, held...)
+	}
+	return held
+}
+
+// sameOutput compares chunks or fragments, an empty result equals nil
+func sameOutput[T any](got, want []T) bool {
+	return len(got) == 0 && len(want) == 0 || reflect.DeepEqual(got, want)
+}
+
+// sameLines compares split lines, an empty line or result equals nil
+func sameLines(got, want [][]OutputFragment) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if !sameOutput(got[i], want[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestOutputBufferInterleaving(t *testing.T) {
+	b := NewOutputBuffer(1024)
+	b.Append(false, "building ")
+	b.Append(true, "warning: x\nstill ")
+	b.Append(false, "going\ndone\n")
+
+	want := [][]OutputFragment{
+		{{Text: "building "}, {Stderr: true, Text: "warning: x"}},
+		{{Stderr: true, Text: "still "}, {Text: "going"}},
+		{{Text: "done"}},
+	}
+	if got := b.Lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Lines() = %v, want %v", got, want)
+	}
+	if got, want := b.Stream(false), "building going\ndone\n"; got != want {
+		t.Errorf("Stream(false) = %q, want %q", got, want)
+	}
+	if got, want := b.Stream(true), "warning: x\nstill "; got != want {
+		t.Errorf("Stream(true) = %q, want %q", got, want)
+	}
+	if b.Dropped() != 0 {
+		t.Errorf("Dropped() = %d, want 0", b.Dropped())
+	}
+}
+
+func TestOutputBufferTrim(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int
+		appends []OutputChunk
+		want    [][]OutputFragment
+		dropped int
+	}{
+		{
+			name:    "whole lines dropped",
+			limit:   8,
+			appends: []OutputChunk{{Data: "one\ntwo\nsix\n"}},
+			want:    [][]OutputFragment{{{Text: "two"}}, {{Text: "six"}}},
+			dropped: 4,
+		},
+		{
+			name:    "cut into the first line",
+			limit:   5,
+			appends: []OutputChunk{{Data: "abc\n"}, {Stderr: true, Data: "de\n"}},
+			want:    [][]OutputFragment{{{Text: "c"}}, {{Stderr: true, Text: "de"}}},
+			dropped: 2,
+		},
+		{
+			name:    "cut across fragments",
+			limit:   2,
+			appends: []OutputChunk{{Data: "ab"}, {Stderr: true, Data: "cd"}, {Data: "e"}},
+			want:    [][]OutputFragment{{{Stderr: true, Text: "d"}, {Text: "e"}}},
+			dropped: 3,
+		},
+		{
+			name:    "chunk larger than the buffer",
+			limit:   4,
+			appends: []OutputChunk{{Data: "old\n"}, {Data: "0123456789"}},
+			want:    [][]OutputFragment{{{Text: "6789"}}},
+			dropped: 10,
+		},
+		{
+			name:    "cut moves to a rune boundary",
+			limit:   4,
+			appends: []OutputChunk{{Data: "aé€\n"}},
+			want:    [][]OutputFragment{{{Text: "€"}}},
+			dropped: 3,
+		},
+		{
+			name:    "oversized chunk moves to a rune boundary",
+			limit:   5,
+			appends: []OutputChunk{{Data: "ab€€"}},
+			want:    [][]OutputFragment{{{Text: "€"}}},
+			dropped: 5,
+		},
+		{
+			name:    "rune split between chunks",
+			limit:   3,
+			appends: []OutputChunk{{Data: "ab\xe2"}, {Data: "\x82\xacc"}},
+			want:    [][]OutputFragment{{{Text: "c"}}},
+			dropped: 5,
+		},
+		{
+			name:    "no rune of the chunk fits",
+			limit:   3,
+			appends: []OutputChunk{{Data: "ab\n"}, {Data: "😀"}},
+			want:    nil,
+			dropped: 7,
+		},
+		{
+			name:    "binary output is cut a few bytes late at most",
+			limit:   4,
+			appends: []OutputChunk{{Data: "\x80\x80\x80\x80\x80\x80\x80\x80"}},
+			want:    [][]OutputFragment{{{Text: "\x80"}}},
+			dropped: 7,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewOutputBuffer(tt.limit)
+			for _, chunk := range tt.appends {
+				b.Append(chunk.Stderr, chunk.Data)
+			}
+			if got := b.Lines(); !sameLines(got, tt.want) {
+				t.Errorf("Lines() = %+v, want %+v", got, tt.want)
+			}
+			if b.Dropped() != tt.dropped {
+				t.Errorf("Dropped() = %d, want %d", b.Dropped(), tt.dropped)
+			}
+		})
+	}
+}
+
+func TestOutputBufferWraparound(t *testing.T) {
+	// Random chunks of whole runes wrap the ring many times and cut lines at
+	// every possible point, the lines must always match a fresh split of the
+	// bytes still held
+	pieces := []string{"a", "bc", "\n", "é", "€", "😀", "line\n", "\n\n", "xyz"}
+	for _, limit := range []int{1, 7, 64, 1000} {
+		rng := rand.New(rand.NewSource(int64(limit)))
+		b := NewOutputBuffer(limit)
+		var appended []OutputChunk
+		total := 0
+		for i := 0; i < 2000; i++ {
+			var sb strings.Builder
+			for n := rng.Intn(4) + 1; n > 0; n-- {
+				sb.WriteString(pieces[rng.Intn(len(pieces))])
+			}
+			chunk := OutputChunk{Stderr: rng.Intn(3) == 0, Data: sb.String()}
+			b.Append(chunk.Stderr, chunk.Data)
+			appended = append(appended, chunk)
+			total += len(chunk.Data)
+
+			held := heldBytes(appended, total-b.Dropped())
+			if !sameOutput(b.Chunks(), held) {
+				t.Fatalf("limit %d, append %d: chunks %+v, want %+v", limit, i, b.Chunks(), held)
+			}
+			if got, want := b.Lines(), referenceLines(held); !sameLines(got, want) {
+				t.Fatalf("limit %d, append %d: lines %+v, want %+v", limit, i, got, want)
+			}
+			if size := total - b.Dropped(); size > limit || size < limit-utf8.UTFMax+1 && total >= limit {
+				t.Fatalf("limit %d, append %d: holding %d bytes", limit, i, size)
+			}
+			for _, line := range b.Lines() {
+				for _, fragment := range line {
+					if !utf8.ValidString(fragment.Text) {
+						t.Fatalf("limit %d, append %d: fragment %q is not valid UTF-8", limit, i, fragment.Text)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// ExecRecord is a finished command in machine-readable form, one per connection
+type ExecRecord struct {
+	Alias      string    `json:"alias"`
+	Host       string    `json:"host"`
+	Command    string    `json:"command"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMS int64     `json:"duration_ms"`
+	ExitCode   *int      `json:"exit_code"` // Null when the command never exited on its own
+	Stdout     string    `json:"stdout"`
+	Stderr     string    `json:"stderr"`
+	Error      string    `json:"error,omitempty"` // Connection failure, timeout or interruption
+}
@@ -0,0 +1,137 @@
+package model
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ConnectionHistorySize is how many commands each connection remembers
+const ConnectionHistorySize = 200
+
+// AddToHistory adds a command to the connection's own history
+func (c *Connection) AddToHistory(cmd string) {
+	c.CommandHistory = appendHistory(c.CommandHistory, cmd, ConnectionHistorySize)
+}
+
+// appendHistory appends cmd, dropping the oldest beyond limit
+// Repeats are kept so SearchHistory can rank by how often a command ran
+func appendHistory(commands []string, cmd string, limit int) []string {
+	if cmd == "" {
+		return commands
+	}
+	commands = append(commands, cmd)
+	if len(commands) > limit {
+		commands = commands[len(commands)-limit:]
+	}
+	return commands
+}
+
+// CollapseRepeats returns the history with runs of the same command listed once
+func CollapseRepeats(commands []string) []string {
+	collapsed := make([]string, 0, len(commands))
+	for _, cmd := range commands {
+		if len(collapsed) == 0 || collapsed[len(collapsed)-1] != cmd {
+			collapsed = append(collapsed, cmd)
+		}
+	}
+	return collapsed
+}
+
+// HistoryMatch is a command found by SearchHistory
+type HistoryMatch struct {
+	Command   string
+	Positions []int // Rune offsets of the query characters in Command
+	Count     int   // Times the command appears in the history
+	score     float64
+	last      int // Index of the most recent run
+}
+
+// SearchHistory fuzzy-matches query against a history, oldest first
+// Every query character must appear in order, case-insensitively
+// Matches are ranked by how often and how recently the command ran, with
+// tighter matches ahead of scattered ones, an empty query matches everything
+func SearchHistory(commands []string, query string) []HistoryMatch {
+	byCommand := make(map[string]*HistoryMatch)
+	var matches []*HistoryMatch
+	for i, cmd := range commands {
+		match, ok := byCommand[cmd]
+		if !ok {
+			positions, quality := fuzzyMatch(cmd, query)
+			if positions == nil && query != "" {
+				byCommand[cmd] = nil
+				continue
+			}
+			match = &HistoryMatch{Command: cmd, Positions: positions, score: quality}
+			byCommand[cmd] = match
+			matches = append(matches, match)
+		} else if match == nil {
+			continue
+		}
+		match.Count++
+		match.last = i
+	}
+
+	for _, match := range matches {
+		// Frequency counts, but a run ten commands ago beats many runs long ago
+		age := float64(len(commands) - 1 - match.last)
+		match.score *= (1 + float64(match.Count)/4) / (1 + age/10)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].last > matches[j].last
+	})
+
+	result := make([]HistoryMatch, 0, len(matches))
+	for _, match := range matches {
+		result = append(result, *match)
+	}
+	return result
+}
+
+// fuzzyMatch finds the query characters in s in order, preferring the
+// shortest span, and rates the match between 0 and 1
+// Returns nil positions when s doesn't match
+func fuzzyMatch(s, query string) ([]int, float64) {
+	if query == "" {
+		return nil, 1
+	}
+	text := []rune(strings.ToLower(s))
+	pattern := []rune(strings.ToLower(query))
+
+	var best []int
+	for start := range text {
+		if text[start] != pattern[0] {
+			continue
+		}
+		positions := []int{start}
+		for i := start + 1; i < len(text) && len(positions) < len(pattern); i++ {
+			if text[i] == pattern[len(positions)] {
+				positions = append(positions, i)
+			}
+		}
+		if len(positions) < len(pattern) {
+			break // No later start can match either
+		}
+		if best == nil || span(positions) < span(best) {
+			best = positions
+		}
+	}
+	if best == nil {
+		return nil, 0
+	}
+
+	quality := float64(len(pattern)) / float64(span(best))
+	if best[0] == 0 || (!unicode.IsLetter(text[best[0]-1]) && !unicode.IsDigit(text[best[0]-1])) {
+		// Matches starting a word read like what was typed
+		quality *= 1.5
+	}
+	return best, quality
+}
+
+// span is the number of runes between the first and last position inclusive
+func span(positions []int) int {
+	return positions[len(positions)-1] - positions[0] + 1
+}
@@ -0,0 +1,184 @@
+package model
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		s, query  string
+		positions []int
+	}{
+		{name: "empty query", s: "ls -la", positions: nil},
+		{name: "prefix", s: "git status", query: "git", positions: []int{0, 1, 2}},
+		{name: "subsequence", s: "git status", query: "gst", positions: []int{0, 4, 5}},
+		{name: "wrong order", s: "git status", query: "tig"},
+		{name: "missing character", s: "git status", query: "gx"},
+		{name: "query longer than text", s: "ls", query: "lsof"},
+		{name: "case-insensitive", s: "Docker PS", query: "dockerps", positions: []int{0, 1, 2, 3, 4, 5, 7, 8}},
+		{name: "shortest span wins", s: "a_b ab", query: "ab", positions: []int{4, 5}},
+		{name: "rune offsets", s: "café au lait", query: "él", positions: []int{3, 8}},
+		{name: "lowercasing keeps rune count", s: "İstanbul", query: "st", positions: []int{1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			positions, quality := fuzzyMatch(tt.s, tt.query)
+			if !reflect.DeepEqual(positions, tt.positions) {
+				t.Errorf("positions %v, want %v", positions, tt.positions)
+			}
+			if tt.query != "" && tt.positions == nil && quality != 0 {
+				t.Errorf("quality %v for no match, want 0", quality)
+			}
+			if tt.query == "" && quality != 1 {
+				t.Errorf("quality %v for an empty query, want 1", quality)
+			}
+		})
+	}
+}
+
+func TestFuzzyMatchQuality(t *testing.T) {
+	quality := func(s, query string) float64 {
+		_, q := fuzzyMatch(s, query)
+		return q
+	}
+	if tight, scattered := quality("make", "mk"), quality("m_a_k", "mk"); tight <= scattered {
+		t.Errorf("tight match %v not ahead of scattered %v", tight, scattered)
+	}
+	if word, inner := quality("go test", "test"), quality("gotest", "test"); word <= inner {
+		t.Errorf("match at a word start %v not ahead of inside a word %v", word, inner)
+	}
+}
+
+// commands returns the commands of matches in order
+func commands(matches []HistoryMatch) []string {
+	out := []string{}
+	for _, match := range matches {
+		out = append(out, match.Command)
+	}
+	return out
+}
+
+func TestSearchHistory(t *testing.T) {
+	// Thirty unrelated commands between two runs push the first far back
+	var filler []string
+	for i := 0; i < 30; i++ {
+		filler = append(filler, "ls")
+	}
+
+	tests := []struct {
+		name    string
+		history []string
+		query   string
+		want    []string
+		counts  []int
+	}{
+		{
+			name:    "non-matching commands left out",
+			history: []string{"git status", "ls", "make test"},
+			query:   "st",
+			want:    []string{"git status", "make test"},
+			counts:  []int{1, 1},
+		},
+		{
+			name:    "nothing matches",
+			history: []string{"ls", "pwd"},
+			query:   "git",
+			want:    []string{},
+		},
+		{
+			name:    "empty query matches everything",
+			history: []string{"ls", "pwd", "ls"},
+			want:    []string{"ls", "pwd"},
+			counts:  []int{2, 1},
+		},
+		{
+			name:    "frequency beats a slightly newer command",
+			history: []string{"git status", "git status", "git status", "git push", "ls"},
+			query:   "git",
+			want:    []string{"git status", "git push"},
+			counts:  []int{3, 1},
+		},
+		{
+			name:    "recency beats many runs long ago",
+			history: append(append([]string{"git status", "git status", "git status"}, filler...), "git push"),
+			query:   "git",
+			want:    []string{"git push", "git status"},
+			counts:  []int{1, 3},
+		},
+		{
+			name:    "equal scores go newest first",
+			history: []string{"git pull", "git push"},
+			query:   "git",
+			want:    []string{"git push", "git pull"},
+			counts:  []int{1, 1},
+		},
+		{
+			name:    "tight match beats a scattered one",
+			history: []string{"make", "m_a_k_e"},
+			query:   "mk",
+			want:    []string{"make", "m_a_k_e"},
+			counts:  []int{1, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := SearchHistory(tt.history, tt.query)
+			if got := commands(matches); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+			for i, match := range matches {
+				if match.Count != tt.counts[i] {
+					t.Errorf("%q counted %d times, want %d", match.Command, match.Count, tt.counts[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSearchHistoryPositions(t *testing.T) {
+	matches := SearchHistory([]string{"echo ÄÖÜ done"}, "üd")
+	if len(matches) != 1 {
+		t.Fatalf("got %d matches, want 1", len(matches))
+	}
+	runes := []rune(matches[0].Command)
+	var picked []string
+	for _, pos := range matches[0].Positions {
+		picked = append(picked, string(runes[pos]))
+	}
+	if got := strings.Join(picked, ""); got != "Üd" {
+		t.Errorf("positions %v pick %q, want %q", matches[0].Positions, got, "Üd")
+	}
+}
+
+func TestCollapseRepeats(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands []string
+		want     []string
+	}{
+		{name: "empty", want: []string{}},
+		{name: "no repeats", commands: []string{"a", "b", "c"}, want: []string{"a", "b", "c"}},
+		{name: "adjacent repeats", commands: []string{"a", "a", "b", "a", "a"}, want: []string{"a", "b", "a"}},
+		{name: "all the same", commands: []string{"ls", "ls", "ls"}, want: []string{"ls"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CollapseRepeats(tt.commands); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppendHistory(t *testing.T) {
+	var commands []string
+	for _, cmd := range []string{"a", "", "b", "b", "c"} {
+		commands = appendHistory(commands, cmd, 3)
+	}
+	if want := []string{"b", "b", "c"}; !reflect.DeepEqual(commands, want) {
+		t.Errorf("got %q, want %q", commands, want)
+	}
+}
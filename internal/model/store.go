@@ -0,0 +1,275 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// CommandHistorySize is how many commands the global history remembers
+const CommandHistorySize = 1000
+
+// jsonObject is a decoded JSON object whose values are left encoded
+type jsonObject = map[string]json.RawMessage
+
+// writeFileAtomic replaces a file so readers see either the old or the new
+// contents, never a partial write, even if beacon crashes halfway
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	// The data must be on disk before the rename makes it visible
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// readConfigFile returns the compacted, migrated contents of the config file, nil if there is none
+func readConfigFile(configPath string) ([]byte, error) {
+	data, err := os.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	migrated, _, err := migrateConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, migrated); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	return compact.Bytes(), nil
+}
+
+// mergeConfig merges the changes this instance made since base (ours) with
+// the config another instance saved meanwhile (theirs)
+// Every field, and every field of every connection, takes our value when we
+// changed it and theirs otherwise, histories keep the commands of both
+// A nil base treats everything as added on both sides
+func mergeConfig(base, ours, theirs []byte) ([]byte, error) {
+	var b, o, t jsonObject
+	if base != nil {
+		if err := json.Unmarshal(base, &b); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal(ours, &o); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(theirs, &t); err != nil {
+		return nil, err
+	}
+
+	merged, err := mergeObjects(b, o, t, map[string]mergeFunc{
+		"connections":     mergeConnections,
+		"command_history": historyMerger(CommandHistorySize),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(merged)
+}
+
+// mergeFunc three-way merges one field, returning nil to leave it out
+type mergeFunc func(base, ours, theirs json.RawMessage) (json.RawMessage, error)
+
+// mergeObjects three-way merges two versions of an object field by field
+// Fields with a mergeFunc are merged by it, the rest take our value if we
+// changed it since base, theirs otherwise
+func mergeObjects(base, ours, theirs jsonObject, special map[string]mergeFunc) (jsonObject, error) {
+	keys := make(map[string]bool)
+	for _, object := range []jsonObject{base, ours, theirs} {
+		for key := range object {
+			keys[key] = true
+		}
+	}
+
+	merged := make(jsonObject)
+	for key := range keys {
+		b, o, t := base[key], ours[key], theirs[key]
+		value := t
+		if merge, ok := special[key]; ok {
+			var err error
+			if value, err = merge(b, o, t); err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+		} else if !bytes.Equal(o, b) {
+			value = o
+		}
+		if value != nil {
+			merged[key] = value
+		}
+	}
+	return merged, nil
+}
+
+// mergeConnections three-way merges connection lists, matching connections by alias
+// Their order is kept with our additions at the end, a connection one side
+// deleted stays if the other side changed it
+func mergeConnections(base, ours, theirs json.RawMessage) (json.RawMessage, error) {
+	var b, o, t []jsonObject
+	for _, side := range []struct {
+		raw  json.RawMessage
+		list *[]jsonObject
+	}{{base, &b}, {ours, &o}, {theirs, &t}} {
+		if side.raw != nil {
+			if err := json.Unmarshal(side.raw, side.list); err != nil {
+				return nil, err
+			}
+		}
+	}
+	byAlias := func(list []jsonObject) map[string]jsonObject {
+		m := make(map[string]jsonObject)
+		for _, conn := range list {
+			m[connectionAlias(conn)] = conn
+		}
+		return m
+	}
+	baseByAlias, oursByAlias, theirsByAlias := byAlias(b), byAlias(o), byAlias(t)
+
+	var order []string
+	seen := make(map[string]bool)
+	for _, list := range [][]jsonObject{t, o} {
+		for _, conn := range list {
+			if alias := connectionAlias(conn); !seen[alias] {
+				seen[alias] = true
+				order = append(order, alias)
+			}
+		}
+	}
+
+	merged := make([]jsonObject, 0, len(order))
+	for _, alias := range order {
+		bc, oc, tc := baseByAlias[alias], oursByAlias[alias], theirsByAlias[alias]
+		var conn jsonObject
+		switch {
+		case oc == nil && tc == nil:
+			continue
+		case oc == nil:
+			// Deleted by us, unless they changed it meanwhile
+			if bc != nil && sameObject(bc, tc) {
+				continue
+			}
+			conn = tc
+		case tc == nil:
+			// Deleted by them, unless we changed it meanwhile
+			if bc != nil && sameObject(bc, oc) {
+				continue
+			}
+			conn = oc
+		default:
+			var err error
+			conn, err = mergeObjects(bc, oc, tc, map[string]mergeFunc{
+				"command_history": historyMerger(ConnectionHistorySize),
+			})
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", alias, err)
+			}
+		}
+		merged = append(merged, conn)
+	}
+	return json.Marshal(merged)
+}
+
+// connectionAlias returns the alias of an encoded connection
+func connectionAlias(conn jsonObject) string {
+	var alias string
+	json.Unmarshal(conn["alias"], &alias)
+	return alias
+}
+
+// sameObject reports whether two encoded objects hold the same fields
+func sameObject(a, b jsonObject) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if !bytes.Equal(value, b[key]) {
+			return false
+		}
+	}
+	return true
+}
+
+// historyMerger returns a mergeFunc that adds the commands we ran since base
+// to their history, keeping at most limit commands
+func historyMerger(limit int) mergeFunc {
+	return func(base, ours, theirs json.RawMessage) (json.RawMessage, error) {
+		var b, o, t []string
+		for _, side := range []struct {
+			raw  json.RawMessage
+			list *[]string
+		}{{base, &b}, {ours, &o}, {theirs, &t}} {
+			if side.raw != nil {
+				if err := json.Unmarshal(side.raw, side.list); err != nil {
+					return nil, err
+				}
+			}
+		}
+		for _, cmd := range newCommands(b, o) {
+			t = appendHistory(t, cmd, limit)
+		}
+		if t == nil {
+			return nil, nil
+		}
+		return json.Marshal(t)
+	}
+}
+
+// newCommands returns the commands appended to a history since base
+// Both are oldest first and the oldest commands may have been dropped since,
+// so ours starts with the longest tail of base it repeats
+func newCommands(base, ours []string) []string {
+	for overlap := min(len(base), len(ours)); overlap > 0; overlap-- {
+		if slices.Equal(base[len(base)-overlap:], ours[:overlap]) {
+			return ours[overlap:]
+		}
+	}
+	return ours
+}
+
+// lockConfig serializes config writes between beacon instances
+// The lock is held on a separate file, the config itself gets replaced on every write
+func lockConfig(configPath string) (unlock func(), err error) {
+	f, err := os.OpenFile(configPath+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config lock: %w", err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock config: %w", err)
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,450 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// encodeConfig encodes a config the way SaveConfig does before merging
+func encodeConfig(t *testing.T, config *Config) []byte {
+	t.Helper()
+	if config == nil {
+		return nil
+	}
+	data, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// aliases returns the aliases of connections in order
+func aliases(conns []*Connection) []string {
+	list := make([]string, 0, len(conns))
+	for _, conn := range conns {
+		list = append(list, conn.Alias)
+	}
+	return list
+}
+
+func TestMergeConfig(t *testing.T) {
+	web := &Connection{Alias: "web", Host: "web.example.com", Port: 22, User: "deploy"}
+	db := &Connection{Alias: "db", Host: "db.example.com", Port: 22, User: "postgres"}
+	with := func(conn *Connection, edit func(*Connection)) *Connection {
+		c := *conn
+		edit(&c)
+		return &c
+	}
+	config := func(conns ...*Connection) *Config {
+		return &Config{Version: ConfigVersion, Connections: conns}
+	}
+
+	tests := []struct {
+		name   string
+		base   *Config
+		ours   *Config
+		theirs *Config
+		want   *Config
+	}{
+		{
+			name:   "add on both sides",
+			base:   config(web),
+			ours:   config(web, db),
+			theirs: config(web, &Connection{Alias: "cache", Host: "cache"}),
+			want:   config(web, &Connection{Alias: "cache", Host: "cache"}, db),
+		},
+		{
+			name:   "same alias added on both sides keeps ours",
+			base:   config(),
+			ours:   config(web),
+			theirs: config(with(web, func(c *Connection) { c.Host = "other" })),
+			want:   config(web),
+		},
+		{
+			name:   "no base treats everything as added",
+			ours:   config(web),
+			theirs: config(db),
+			want:   config(db, web),
+		},
+		{
+			name:   "edits to different fields combine",
+			base:   config(web),
+			ours:   config(with(web, func(c *Connection) { c.Port = 2222 })),
+			theirs: config(with(web, func(c *Connection) { c.User = "admin" })),
+			want:   config(with(web, func(c *Connection) { c.Port = 2222; c.User = "admin" })),
+		},
+		{
+			name:   "edits to the same field keep ours",
+			base:   config(web),
+			ours:   config(with(web, func(c *Connection) { c.Port = 2222 })),
+			theirs: config(with(web, func(c *Connection) { c.Port = 2200 })),
+			want:   config(with(web, func(c *Connection) { c.Port = 2222 })),
+		},
+		{
+			name:   "we edit what they deleted",
+			base:   config(web, db),
+			ours:   config(web, with(db, func(c *Connection) { c.Port = 5432 })),
+			theirs: config(web),
+			want:   config(web, with(db, func(c *Connection) { c.Port = 5432 })),
+		},
+		{
+			name:   "they edit what we deleted",
+			base:   config(web, db),
+			ours:   config(web),
+			theirs: config(web, with(db, func(c *Connection) { c.Port = 5432 })),
+			want:   config(web, with(db, func(c *Connection) { c.Port = 5432 })),
+		},
+		{
+			name:   "we delete what they kept",
+			base:   config(web, db),
+			ours:   config(web),
+			theirs: config(web, db),
+			want:   config(web),
+		},
+		{
+			name:   "they delete what we kept",
+			base:   config(web, db),
+			ours:   config(web, db),
+			theirs: config(db),
+			want:   config(db),
+		},
+		{
+			name:   "both delete",
+			base:   config(web, db),
+			ours:   config(web),
+			theirs: config(web),
+			want:   config(web),
+		},
+		{
+			name: "rename",
+			base: config(web, with(db, func(c *Connection) { c.Jumps = []string{"web"} })),
+			ours: config(
+				with(web, func(c *Connection) { c.Alias = "www" }),
+				with(db, func(c *Connection) { c.Jumps = []string{"www"} }),
+			),
+			theirs: config(web, with(db, func(c *Connection) { c.Jumps = []string{"web"}; c.User = "admin" })),
+			want: config(
+				with(db, func(c *Connection) { c.Jumps = []string{"www"}; c.User = "admin" }),
+				with(web, func(c *Connection) { c.Alias = "www" }),
+			),
+		},
+		{
+			name:   "rename of a connection they edited keeps both",
+			base:   config(web),
+			ours:   config(with(web, func(c *Connection) { c.Alias = "www" })),
+			theirs: config(with(web, func(c *Connection) { c.Port = 2222 })),
+			want: config(
+				with(web, func(c *Connection) { c.Port = 2222 }),
+				with(web, func(c *Connection) { c.Alias = "www" }),
+			),
+		},
+		{
+			name:   "global settings",
+			base:   &Config{Version: ConfigVersion, CommandTimeout: 30},
+			ours:   &Config{Version: ConfigVersion, CommandTimeout: 60},
+			theirs: &Config{Version: ConfigVersion, CommandTimeout: 30, ElevateCommand: "doas"},
+			want:   &Config{Version: ConfigVersion, Connections: []*Connection{}, CommandTimeout: 60, ElevateCommand: "doas"},
+		},
+		{
+			name:   "global history",
+			base:   &Config{Version: ConfigVersion, CommandHistory: []string{"ls", "df"}},
+			ours:   &Config{Version: ConfigVersion, CommandHistory: []string{"ls", "df", "uptime", "uptime"}},
+			theirs: &Config{Version: ConfigVersion, CommandHistory: []string{"ls", "df", "free"}},
+			want:   &Config{Version: ConfigVersion, Connections: []*Connection{}, CommandHistory: []string{"ls", "df", "free", "uptime", "uptime"}},
+		},
+		{
+			name: "connection history",
+			base: config(with(web, func(c *Connection) { c.CommandHistory = []string{"ls"} })),
+			ours: config(with(web, func(c *Connection) { c.CommandHistory = []string{"ls", "df"} })),
+			theirs: config(with(web, func(c *Connection) {
+				c.CommandHistory = []string{"ls", "free"}
+				c.Port = 2222
+			})),
+			want: config(with(web, func(c *Connection) {
+				c.CommandHistory = []string{"ls", "free", "df"}
+				c.Port = 2222
+			})),
+		},
+		{
+			name: "history added on both sides",
+			base: config(web),
+			ours: config(with(web, func(c *Connection) { c.CommandHistory = []string{"df"} })),
+			theirs: config(with(web, func(c *Connection) {
+				c.CommandHistory = []string{"free"}
+			})),
+			want: config(with(web, func(c *Connection) { c.CommandHistory = []string{"free", "df"} })),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, err := mergeConfig(encodeConfig(t, tt.base), encodeConfig(t, tt.ours), encodeConfig(t, tt.theirs))
+			if err != nil {
+				t.Fatalf("mergeConfig: %v", err)
+			}
+			var got Config
+			if err := json.Unmarshal(merged, &got); err != nil {
+				t.Fatal(err)
+			}
+			if want := string(encodeConfig(t, tt.want)); string(encodeConfig(t, &got)) != want {
+				t.Errorf("merged\n%s\nwant\n%s", encodeConfig(t, &got), want)
+			}
+		})
+	}
+}
+
+func TestMergeConfigInvalid(t *testing.T) {
+	valid := encodeConfig(t, &Config{Version: ConfigVersion})
+	for _, tt := range []struct {
+		name               string
+		base, ours, theirs string
+	}{
+		{"base", "{", string(valid), string(valid)},
+		{"ours", string(valid), "[]", string(valid)},
+		{"connections", string(valid), string(valid), `{"connections": {}}`},
+		{"history", string(valid), string(valid), `{"command_history": "ls"}`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := mergeConfig([]byte(tt.base), []byte(tt.ours), []byte(tt.theirs)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestHistoryMerger(t *testing.T) {
+	tests := []struct {
+		name   string
+		base   []string
+		ours   []string
+		theirs []string
+		limit  int
+		want   []string
+	}{
+		{name: "nothing new", base: []string{"a"}, ours: []string{"a"}, theirs: []string{"a", "b"}, limit: 10,
+			want: []string{"a", "b"}},
+		{name: "ours appended", base: []string{"a"}, ours: []string{"a", "b", "b"}, theirs: []string{"a", "c"}, limit: 10,
+			want: []string{"a", "c", "b", "b"}},
+		{name: "limit drops the oldest", base: []string{"a", "b"}, ours: []string{"a", "b", "c"}, theirs: []string{"a", "b", "d"}, limit: 3,
+			want: []string{"b", "d", "c"}},
+		{name: "ours dropped the oldest", base: []string{"a", "b", "c"}, ours: []string{"b", "c", "d"}, theirs: []string{"a", "b", "c"}, limit: 3,
+			want: []string{"b", "c", "d"}},
+		{name: "missing everywhere", limit: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encode := func(list []string) json.RawMessage {
+				if list == nil {
+					return nil
+				}
+				data, err := json.Marshal(list)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return data
+			}
+			merged, err := historyMerger(tt.limit)(encode(tt.base), encode(tt.ours), encode(tt.theirs))
+			if err != nil {
+				t.Fatalf("historyMerger: %v", err)
+			}
+			var got []string
+			if merged != nil {
+				if err := json.Unmarshal(merged, &got); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewCommands(t *testing.T) {
+	tests := []struct {
+		name string
+		base []string
+		ours []string
+		want []string
+	}{
+		{name: "empty base", ours: []string{"a", "b"}, want: []string{"a", "b"}},
+		{name: "unchanged", base: []string{"a", "b"}, ours: []string{"a", "b"}, want: []string{}},
+		{name: "appended", base: []string{"a"}, ours: []string{"a", "b", "c"}, want: []string{"b", "c"}},
+		{name: "repeat of the last command", base: []string{"a", "b"}, ours: []string{"a", "b", "b"}, want: []string{"b"}},
+		{name: "oldest dropped", base: []string{"a", "b", "c"}, ours: []string{"b", "c", "d"}, want: []string{"d"}},
+		{name: "all of base dropped", base: []string{"a", "b"}, ours: []string{"c", "d"}, want: []string{"c", "d"}},
+		{name: "longest overlap wins", base: []string{"x", "x"}, ours: []string{"x", "x", "x"}, want: []string{"x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newCommands(tt.base, tt.ours)
+			if len(got) != len(tt.want) || (len(got) > 0 && !reflect.DeepEqual(got, tt.want)) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// useConfigDir points ConfigPath at a fresh temporary home and returns the config path
+func useConfigDir(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	path, err := ConfigPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func loadConfig(t *testing.T) *Config {
+	t.Helper()
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	return config
+}
+
+func saveConfig(t *testing.T, config *Config) {
+	t.Helper()
+	if err := SaveConfig(config); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+}
+
+func TestSaveConfigMergesOtherInstances(t *testing.T) {
+	useConfigDir(t)
+	initial := loadConfig(t)
+	initial.Connections = []*Connection{
+		{Alias: "web", Host: "web.example.com", Port: 22},
+		{Alias: "db", Host: "db.example.com", Port: 22},
+	}
+	saveConfig(t, initial)
+
+	a, b := loadConfig(t), loadConfig(t)
+
+	a.Connections = append(a.Connections, &Connection{Alias: "cache", Host: "cache"})
+	a.CommandHistory = append(a.CommandHistory, "uptime")
+	saveConfig(t, a)
+
+	if err := b.RemoveConnection("db"); err != nil {
+		t.Fatal(err)
+	}
+	b.CommandHistory = append(b.CommandHistory, "df")
+	saveConfig(t, b)
+
+	got := loadConfig(t)
+	if want := []string{"web", "cache"}; !reflect.DeepEqual(aliases(got.Connections), want) {
+		t.Errorf("connections %q, want %q", aliases(got.Connections), want)
+	}
+	if want := []string{"uptime", "df"}; !reflect.DeepEqual(got.CommandHistory, want) {
+		t.Errorf("history %q, want %q", got.CommandHistory, want)
+	}
+
+	// a merges against what it saved last, so b's delete sticks
+	a.FindConnection("web").Port = 2222
+	saveConfig(t, a)
+	got = loadConfig(t)
+	if want := []string{"web", "cache"}; !reflect.DeepEqual(aliases(got.Connections), want) {
+		t.Errorf("connections %q, want %q", aliases(got.Connections), want)
+	}
+	if port := got.FindConnection("web").Port; port != 2222 {
+		t.Errorf("web port %d, want 2222", port)
+	}
+	if want := []string{"uptime", "df"}; !reflect.DeepEqual(got.CommandHistory, want) {
+		t.Errorf("history %q, want %q", got.CommandHistory, want)
+	}
+}
+
+func TestSaveConfigConcurrent(t *testing.T) {
+	path := useConfigDir(t)
+	saveConfig(t, loadConfig(t))
+
+	const instances = 8
+	configs := make([]*Config, instances)
+	for i := range configs {
+		configs[i] = loadConfig(t)
+		alias := fmt.Sprintf("host%d", i)
+		configs[i].Connections = append(configs[i].Connections, &Connection{Alias: alias, Host: alias})
+		configs[i].CommandHistory = append(configs[i].CommandHistory, "ls "+alias)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, instances)
+	for i, config := range configs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = SaveConfig(config)
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("SaveConfig %d: %v", i, err)
+		}
+	}
+
+	got := loadConfig(t)
+	if len(got.Connections) != instances || len(got.CommandHistory) != instances {
+		t.Fatalf("lost a save: connections %q, history %q", aliases(got.Connections), got.CommandHistory)
+	}
+	for i := range instances {
+		alias := fmt.Sprintf("host%d", i)
+		if got.FindConnection(alias) == nil {
+			t.Errorf("connection %s missing", alias)
+		}
+	}
+
+	// Temporary files are renamed into place or removed
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if name := entry.Name(); name != filepath.Base(path) && name != filepath.Base(path)+".lock" {
+			t.Errorf("unexpected file %s left behind", name)
+		}
+	}
+}
+
+func TestLockConfig(t *testing.T) {
+	path := useConfigDir(t)
+	unlock, err := lockConfig(path)
+	if err != nil {
+		t.Fatalf("lockConfig: %v", err)
+	}
+
+	locked := make(chan func())
+	go func() {
+		second, err := lockConfig(path)
+		if err != nil {
+			t.Error(err)
+			second = func() {}
+		}
+		locked <- second
+	}()
+
+	select {
+	case second := <-locked:
+		second()
+		unlock()
+		t.Fatal("second lock acquired while the first was held")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	unlock()
+	select {
+	case second := <-locked:
+		second()
+	case <-time.After(5 * time.Second):
+		t.Fatal("second lock not acquired after unlock")
+	}
+}
@@ -0,0 +1,108 @@
+package model
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/SimonLariz/beacon/internal/ssh"
+)
+
+// ValidateAlias checks that alias can name a connection other than self
+// Pass a nil self for a new connection
+func (c *Config) ValidateAlias(alias string, self *Connection) error {
+	switch {
+	case alias == "":
+		return fmt.Errorf("alias is required")
+	case strings.ContainsAny(alias, " \t"):
+		return fmt.Errorf("alias can't contain spaces")
+	case strings.HasPrefix(alias, GroupSelector) || strings.HasPrefix(alias, TagSelector):
+		return fmt.Errorf("alias can't start with %q or %q", GroupSelector, TagSelector)
+	}
+	if existing := c.FindConnection(alias); existing != nil && existing != self {
+		return fmt.Errorf("connection %q already exists", alias)
+	}
+	return nil
+}
+
+// ValidateHost checks that host is an IP address or a hostname
+// Underscores are accepted, ssh config aliases often use them
+func ValidateHost(host string) error {
+	if host == "" {
+		return fmt.Errorf("host is required")
+	}
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	if strings.ContainsAny(host, "[]") {
+		// Brackets would end up doubled when the port is joined on
+		return fmt.Errorf("write IPv6 addresses without brackets")
+	}
+	if len(host) > 253 {
+		return fmt.Errorf("hostname is longer than 253 characters")
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if label == "" {
+			return fmt.Errorf("hostname has an empty label")
+		}
+		if len(label) > 63 {
+			return fmt.Errorf("hostname label %q is longer than 63 characters", label)
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Errorf("hostname label %q starts or ends with a hyphen", label)
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+				return fmt.Errorf("hostname can't contain %q", r)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateUser checks an optional SSH user name
+func ValidateUser(user string) error {
+	if strings.ContainsAny(user, " \t@:") {
+		return fmt.Errorf("user can't contain spaces, @ or :")
+	}
+	return nil
+}
+
+// ParsePort parses an optional port, 0 when value is blank
+func ParsePort(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	port, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("port must be a number")
+	}
+	if port < 1 || port > 65535 {
+		return 0, fmt.Errorf("port must be between 1 and 65535")
+	}
+	return port, nil
+}
+
+// ValidateKeyPath checks that an optional private key exists and is readable
+func ValidateKeyPath(keyPath string) error {
+	if keyPath == "" {
+		return nil
+	}
+	return ssh.CheckKeyFile(keyPath)
+}
+
+// ValidateJump checks one jump host entry, a saved alias or [user@]host[:port]
+func (c *Config) ValidateJump(entry string) error {
+	if c.FindConnection(entry) != nil {
+		return nil
+	}
+	hop, err := ssh.ParseJumpSpec(entry)
+	if err != nil {
+		return err
+	}
+	if err := ValidateHost(hop.Host); err != nil {
+		return fmt.Errorf("jump host %q: %v", entry, err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package model
+
+import "testing"
+
+func TestValidateHost(t *testing.T) {
+	tests := []struct {
+		host  string
+		valid bool
+	}{
+		{"web.example.com", true},
+		{"web.example.com.", true},
+		{"localhost", true},
+		{"10.0.0.5", true},
+		{"::1", true},
+		{"fe80::1", true},
+		{"2001:db8::8a2e:370:7334", true},
+		{"::ffff:10.0.0.5", true},
+		{"[::1]", false},
+		{"[::1", false},
+		{"fe80::1%eth0", false},
+		{"my_server", true},
+		{"db_1.internal", true},
+		{"", false},
+		{" web", false},
+		{"web ", false},
+		{" ::1", false},
+		{"\tweb", false},
+		{"web..example.com", false},
+		{"-web", false},
+		{"web-", false},
+		{"web:22", false},
+		{"user@web", false},
+		{"wéb", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if err := ValidateHost(tt.host); (err == nil) != tt.valid {
+				t.Errorf("ValidateHost(%q) = %v, want valid %v", tt.host, err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestValidateHostLengths(t *testing.T) {
+	label := func(n int) string {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = 'a'
+		}
+		return string(b)
+	}
+	if err := ValidateHost(label(63)); err != nil {
+		t.Errorf("63 character label: %v", err)
+	}
+	if err := ValidateHost(label(64)); err == nil {
+		t.Error("64 character label accepted")
+	}
+	long := label(63) + "." + label(63) + "." + label(63) + "." + label(61)
+	if err := ValidateHost(long); err != nil {
+		t.Errorf("253 character hostname: %v", err)
+	}
+	if err := ValidateHost(long + "a"); err == nil {
+		t.Error("254 character hostname accepted")
+	}
+}
+
+func TestParsePort(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+		valid bool
+	}{
+		{"", 0, true},
+		{"22", 22, true},
+		{"1", 1, true},
+		{"65535", 65535, true},
+		{"0", 0, false},
+		{"65536", 0, false},
+		{"-22", 0, false},
+		{" 22", 0, false},
+		{"22 ", 0, false},
+		{" ", 0, false},
+		{"ssh", 0, false},
+		{"22.5", 0, false},
+		{"99999999999999999999", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParsePort(tt.value)
+			if (err == nil) != tt.valid {
+				t.Fatalf("ParsePort(%q) error %v, want valid %v", tt.value, err, tt.valid)
+			}
+			if got != tt.want {
+				t.Errorf("ParsePort(%q) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
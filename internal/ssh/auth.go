@@ -0,0 +1,111 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// maxPasswordAttempts matches OpenSSH's default NumberOfPasswordPrompts
+const maxPasswordAttempts = 3
+
+// PromptKind identifies what kind of secret the ssh layer is asking for
+type PromptKind int
+
+const (
+	PromptPassword   PromptKind = iota // Password authentication
+	PromptChallenge                    // Keyboard-interactive question (OTP, PAM, ...)
+	PromptPassphrase                   // Passphrase for an encrypted private key
+)
+
+// SecretPrompt describes a question that needs an answer from the user
+type SecretPrompt struct {
+	Kind        PromptKind
+	User        string // Remote user being authenticated
+	Host        string // Remote host being authenticated against
+	Jump        bool   // Whether Host is a jump host rather than the target
+	KeyPath     string // Private key being decrypted (PromptPassphrase only)
+	Instruction string // Extra text shown above the question, may be empty
+	Question    string // The question itself, e.g. "Password: "
+	Echo        bool   // Whether the answer may be shown while typing
+}
+
+// SecretPromptFunc asks the user for a secret
+// It is called from the connecting goroutine and may block until answered
+type SecretPromptFunc func(prompt SecretPrompt) (string, error)
+
+// ErrPromptCancelled is returned by prompt functions when the user gives up
+var ErrPromptCancelled = errors.New("prompt cancelled by user")
+
+// getPasswordMethods returns password and keyboard-interactive auth methods
+// A stored password is tried first, after that the user is prompted (if possible)
+func getPasswordMethods(e endpoint, opts ConnectOptions) []ssh.AuthMethod {
+	user, host := e.user, e.hostname
+	if opts.Password == "" && opts.PromptSecret == nil {
+		return []ssh.AuthMethod{}
+	}
+
+	// The stored password is only offered once per method, retries go to the user
+	storedForPassword := opts.Password
+	storedForChallenge := opts.Password
+
+	passwordCallback := func() (string, error) {
+		if storedForPassword != "" {
+			password := storedForPassword
+			storedForPassword = ""
+			return password, nil
+		}
+		if opts.PromptSecret == nil {
+			return "", fmt.Errorf("password required but no prompt available")
+		}
+		return opts.PromptSecret(SecretPrompt{
+			Kind:     PromptPassword,
+			User:     user,
+			Host:     host,
+			Jump:     e.jump,
+			Question: fmt.Sprintf("%s@%s's password: ", user, host),
+		})
+	}
+
+	challengeCallback := func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+		answers := make([]string, len(questions))
+		for i, question := range questions {
+			// PAM usually asks for the password this way, reuse the stored one
+			if storedForChallenge != "" && !echos[i] && strings.Contains(strings.ToLower(question), "password") {
+				answers[i] = storedForChallenge
+				storedForChallenge = ""
+				continue
+			}
+			if opts.PromptSecret == nil {
+				return nil, fmt.Errorf("server asked %q but no prompt available", question)
+			}
+
+			text := instruction
+			if name != "" {
+				text = strings.TrimSpace(name + "\n" + instruction)
+			}
+			answer, err := opts.PromptSecret(SecretPrompt{
+				Kind:        PromptChallenge,
+				User:        user,
+				Host:        host,
+				Jump:        e.jump,
+				Instruction: text,
+				Question:    question,
+				Echo:        echos[i],
+			})
+			if err != nil {
+				return nil, err
+			}
+			answers[i] = answer
+		}
+		return answers, nil
+	}
+
+	// Same order as OpenSSH: keyboard-interactive before password
+	return []ssh.AuthMethod{
+		ssh.RetryableAuthMethod(ssh.KeyboardInteractive(challengeCallback), maxPasswordAttempts),
+		ssh.RetryableAuthMethod(ssh.PasswordCallback(passwordCallback), maxPasswordAttempts),
+	}
+}
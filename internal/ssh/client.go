@@ -1,13 +1,17 @@
 package ssh
 
 import (
-	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"path/filepath"
-	"strings"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/crypto/ssh"
@@ -27,54 +31,207 @@ type SSHClientWrapper struct {
 	client     *ssh.Client
 	config     *ssh.ClientConfig
 	host       string
-	connected  bool
+	jumps      []*ssh.Client // Jump host connections, closed after client
+	connected  atomic.Bool   // Cleared by Disconnect or when keepalive finds the link dead
 	LastActive time.Time
+
+	forwardsMu sync.Mutex
+	forwards   []*Forward // Port forwards, closed with the connection
+}
+
+// ConnectOptions holds optional per-connection settings for Connect
+type ConnectOptions struct {
+	KnownHostsFile string             // Custom known_hosts file, replaces ~/.ssh/known_hosts when set
+	ConfirmHostKey HostKeyConfirmFunc // Asked about unknown hosts, nil rejects them
+	Password       string             // Stored password, tried before prompting
+	PromptSecret   SecretPromptFunc   // Asked for passwords and challenges, nil disables prompting
+	JumpHosts      []JumpHost         // Bastions to go through, replaces ProxyJump from ssh config
+}
+
+// defaultConnectTimeout is used when ssh_config doesn't set ConnectTimeout
+const defaultConnectTimeout = 10 * time.Second
+
+// endpoint is a single SSH server to authenticate against, the target or a jump host
+type endpoint struct {
+	hostname       string // Host to dial
+	port           int
+	user           string
+	keyPath        string   // Explicit key, tried before identity files
+	identityFiles  []string // IdentityFile entries from ssh_config
+	identitiesOnly bool
+	timeout        time.Duration
+	jump           bool // Whether this is a jump host rather than the target
 }
 
-// Connect establishes SSH connection using key-based authentication
-// Tries KeyPath first, then SSH config, then falls back to default keys
-func Connect(host string, port int, user string, keyPath string) (*SSHClientWrapper, error) {
-	address := fmt.Sprintf("%s:%d", host, port)
-	authMethods, err := createAuthMethods(keyPath, host)
+// address returns host:port for dialing and known_hosts lookups
+func (e endpoint) address() string {
+	return net.JoinHostPort(e.hostname, strconv.Itoa(e.port))
+}
+
+// newEndpoint fills in an endpoint from ssh_config, explicit values win
+// A zero port or empty user falls back to ssh_config, then to 22 / the local user
+func newEndpoint(resolved *HostConfig, port int, keyPath string) endpoint {
+	e := endpoint{
+		hostname:       resolved.HostName,
+		port:           port,
+		user:           resolved.User,
+		keyPath:        keyPath,
+		identityFiles:  resolved.IdentityFiles,
+		identitiesOnly: resolved.IdentitiesOnly,
+		timeout:        resolved.ConnectTimeout,
+	}
+	if e.port == 0 {
+		e.port = resolved.Port
+	}
+	if e.port == 0 {
+		e.port = 22
+	}
+	if e.user == "" {
+		e.user = localUsername()
+	}
+	if e.timeout == 0 {
+		e.timeout = defaultConnectTimeout
+	}
+	return e
+}
+
+// Connect establishes SSH connection
+// host may be an alias from ~/.ssh/config: HostName, User, Port, IdentityFile,
+// IdentitiesOnly, ProxyJump and ConnectTimeout are resolved from it, and so
+// are those of every jump host, see ResolveHost for what isn't supported
+// Tries KeyPath first, then SSH config, then default keys, then password/keyboard-interactive
+func Connect(host string, port int, user string, keyPath string, opts ConnectOptions) (*SSHClientWrapper, error) {
+	directives, err := loadSSHConfig()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create auth methods: %v", err)
+		return nil, err
+	}
+	resolved := resolveDirectives(directives, host, user)
+	target := newEndpoint(resolved, port, keyPath)
+
+	// Explicit jump hosts replace ProxyJump from ssh config
+	hops := opts.JumpHosts
+	if len(hops) == 0 {
+		hops, err = parseProxyJump(resolved.ProxyJump)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ProxyJump for %s: %v", host, err)
+		}
+		for i := range hops {
+			hops[i].KnownHostsFile = opts.KnownHostsFile
+		}
+	}
+	hops, err = followProxyJump(directives, hops)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each jump host is dialed through the previous one, with its own auth and host key check
+	var jumps []*ssh.Client
+	var via *ssh.Client
+	for _, hop := range hops {
+		hopResolved := resolveDirectives(directives, hop.Host, hop.User)
+		hopEndpoint := newEndpoint(hopResolved, hop.Port, hop.KeyPath)
+		hopEndpoint.jump = true
+
+		hopOpts := opts
+		hopOpts.KnownHostsFile = hop.KnownHostsFile
+		hopOpts.Password = hop.Password
+
+		hopClient, _, err := dialEndpoint(via, hopEndpoint, hopOpts)
+		if err != nil {
+			closeClients(jumps)
+			return nil, fmt.Errorf("failed to connect to jump host %s: %w", hop, err)
+		}
+		jumps = append(jumps, hopClient)
+		via = hopClient
+	}
+
+	client, sshConfig, err := dialEndpoint(via, target, opts)
+	if err != nil {
+		closeClients(jumps)
+		return nil, err
+	}
+
+	wrapper := &SSHClientWrapper{
+		client: client,
+		config: sshConfig,
+		host:   target.address(),
+		jumps:  jumps,
+	}
+	wrapper.connected.Store(true)
+	return wrapper, nil
+}
+
+// dialEndpoint authenticates against e, dialing through via when it's a jump
+func dialEndpoint(via *ssh.Client, e endpoint, opts ConnectOptions) (*ssh.Client, *ssh.ClientConfig, error) {
+	address := e.address()
+
+	agentConn := dialAgent()
+	if agentConn != nil {
+		// The agent is only needed while authenticating
+		defer agentConn.Close()
+	}
+
+	authMethods, err := createAuthMethods(e, agentConn, opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create auth methods: %v", err)
+	}
+
+	verifier, err := newHostKeyVerifier(opts.KnownHostsFile, opts.ConfirmHostKey)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	sshConfig := &ssh.ClientConfig{
-		User:            user,
-		Auth:            authMethods,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // For simplicity; consider verifying host keys in production
-		Timeout:         10 * time.Second,
+		User:              e.user,
+		Auth:              authMethods,
+		HostKeyCallback:   verifier.check,
+		HostKeyAlgorithms: verifier.algorithms(address),
+		Timeout:           e.timeout,
+	}
+
+	var conn net.Conn
+	if via == nil {
+		conn, err = net.DialTimeout("tcp", address, e.timeout)
+	} else {
+		conn, err = via.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to dial SSH: %w", err)
 	}
 
-	client, err := ssh.Dial("tcp", address, sshConfig)
+	clientConn, chans, reqs, err := ssh.NewClientConn(conn, address, sshConfig)
 	if err != nil {
-		return nil, fmt.Errorf("failed to dial SSH: %v", err)
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to dial SSH: %w", err)
 	}
+	return ssh.NewClient(clientConn, chans, reqs), sshConfig, nil
+}
 
-	return &SSHClientWrapper{
-		client:    client,
-		config:    sshConfig,
-		host:      address,
-		connected: true,
-	}, nil
+// closeClients closes jump host connections, innermost first
+func closeClients(clients []*ssh.Client) {
+	for i := len(clients) - 1; i >= 0; i-- {
+		clients[i].Close()
+	}
 }
 
 // Disconnect closes the SSH connection
 func (s *SSHClientWrapper) Disconnect() error {
 	if s.client != nil {
+		// Cleared first so the keepalive loop knows the close was deliberate
+		s.connected.Store(false)
+		s.closeForwards()
 		err := s.client.Close()
+		closeClients(s.jumps)
 		if err != nil {
 			return fmt.Errorf("failed to close SSH connection: %v", err)
 		}
-		s.connected = false
 	}
 	return nil
 }
 
 // IsConnected checks if the SSH client is connected
 func (s *SSHClientWrapper) IsConnected() bool {
-	return s.connected
+	return s.connected.Load()
 }
 
 // Ping tests if connection is still alive
@@ -95,17 +252,45 @@ func (s *SSHClientWrapper) Ping() error {
 // ExecuteCommand runs a command on the remote server and returns the result
 // This is a blocking call - should be wrapped in a goroutine by the caller
 func (s *SSHClientWrapper) ExecuteCommand(cmd string) (*CommandResult, error) {
+	return s.ExecuteCommandContext(context.Background(), cmd)
+}
+
+// ExecuteCommandContext runs a command, interrupting it when ctx is done
+// A cancelled or timed out command still returns a result, with Error set to ctx.Err()
+// This is a blocking call - should be wrapped in a goroutine by the caller
+func (s *SSHClientWrapper) ExecuteCommandContext(ctx context.Context, cmd string) (*CommandResult, error) {
 	start := time.Now()
 
+	// Set up pipes for stdout and stderr
+	var stdoutBuf, stderrBuf bytes.Buffer
+	exitCode, runErr, err := s.runCommand(ctx, cmd, nil, &stdoutBuf, &stderrBuf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CommandResult{
+		Stdout:   stdoutBuf.String(),
+		Stderr:   stderrBuf.String(),
+		ExitCode: exitCode,
+		Duration: time.Since(start),
+		Error:    runErr,
+	}, nil
+}
+
+// runCommand runs cmd in a new session, feeding it stdin and writing its output to stdout/stderr
+// A nil stdin gives the command an empty input
+// A non-zero exit status is returned as the exit code, not as an error
+// When ctx is done the command is stopped and runErr is set to ctx.Err()
+func (s *SSHClientWrapper) runCommand(ctx context.Context, cmd string, stdin io.Reader, stdout, stderr io.Writer) (exitCode int, runErr, err error) {
 	// Check if connected
-	if !s.connected || s.client == nil {
-		return nil, fmt.Errorf("not connected to server")
+	if !s.connected.Load() || s.client == nil {
+		return 0, nil, fmt.Errorf("not connected to server")
 	}
 
 	// Create new session
 	session, err := s.client.NewSession()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create session: %w", err)
+		return 0, nil, fmt.Errorf("failed to create session: %w", err)
 	}
 	defer session.Close()
 
@@ -115,33 +300,58 @@ func (s *SSHClientWrapper) ExecuteCommand(cmd string) (*CommandResult, error) {
 	_ = session.Setenv("LANG", "en_US.UTF-8")
 	_ = session.Setenv("LC_ALL", "en_US.UTF-8")
 
-	// Set up pipes for stdout and stderr
-	var stdoutBuf, stderrBuf bytes.Buffer
-	session.Stdout = &stdoutBuf
-	session.Stderr = &stderrBuf
+	session.Stdin = stdin
+	session.Stdout = stdout
+	session.Stderr = stderr
 
 	// Execute command
-	err = session.Run(cmd)
+	if err := session.Start(cmd); err != nil {
+		return 0, nil, fmt.Errorf("command execution failed: %w", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = stopSession(session, done)
+		runErr = ctx.Err()
+	}
 
 	// Determine exit code
-	exitCode := 0
 	if err != nil {
 		if exitErr, ok := err.(*ssh.ExitError); ok {
-			exitCode = exitErr.ExitStatus()
-			err = nil // Command executed but exited with non-zero code
-		} else {
-			// Connection error or other issue
-			return nil, fmt.Errorf("command execution failed: %w", err)
+			// Command executed but exited with non-zero code
+			return exitErr.ExitStatus(), runErr, nil
+		}
+		if runErr != nil {
+			// Closing the session to stop the command leaves no exit status
+			return -1, runErr, nil
 		}
+		// Connection error or other issue
+		return 0, nil, fmt.Errorf("command execution failed: %w", err)
 	}
+	return 0, runErr, nil
+}
 
-	return &CommandResult{
-		Stdout:   stdoutBuf.String(),
-		Stderr:   stderrBuf.String(),
-		ExitCode: exitCode,
-		Duration: time.Since(start),
-		Error:    err,
-	}, nil
+// stopSession interrupts a running command, escalating from SIGINT to SIGTERM
+// to closing the channel, waiting cancelGracePeriod between each step
+func stopSession(session *ssh.Session, done <-chan error) error {
+	for _, sig := range []ssh.Signal{ssh.SIGINT, ssh.SIGTERM} {
+		// Servers that don't support signals ignore the request
+		_ = session.Signal(sig)
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(cancelGracePeriod):
+		}
+	}
+
+	session.Close()
+	return <-done
 }
 
 // expandPath expands ~ to the user's home directory
@@ -159,8 +369,8 @@ func expandPath(path string) (string, error) {
 	return path, nil
 }
 
-// Loads private key from file path
-// Handles passphrase-protected keys
+// Loads an unencrypted private key from file path
+// Passphrase-protected keys return *ssh.PassphraseMissingError, see loadKeyAuthMethod
 func loadPrivateKey(keyPath string) (ssh.Signer, error) {
 	// Expand ~ to home directory
 	expandedPath, err := expandPath(keyPath)
@@ -175,7 +385,7 @@ func loadPrivateKey(keyPath string) (ssh.Signer, error) {
 
 	signer, err := ssh.ParsePrivateKey(keyData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse private key: %v", err)
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
 	}
 	return signer, nil
 }
@@ -193,141 +403,115 @@ func getDefaultKeyPaths() []string {
 	}
 }
 
-// getSSHConfigKeyPaths reads ~/.ssh/config and returns identity files for a given host
-func getSSHConfigKeyPaths(host string) []string {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return []string{}
+// dialAgent connects to the SSH agent, returns nil when there is none
+func dialAgent() net.Conn {
+	sshAgentAddr := os.Getenv("SSH_AUTH_SOCK")
+	if sshAgentAddr == "" {
+		return nil
 	}
 
-	configPath := filepath.Join(home, ".ssh", "config")
-	file, err := os.Open(configPath)
+	conn, err := net.Dial("unix", sshAgentAddr)
 	if err != nil {
-		return []string{}
+		return nil
 	}
-	defer file.Close()
-
-	var keyPaths []string
-	var currentHost string
-	var hostMatches bool
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+	return conn
+}
 
-		// Skip empty lines and comments
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
+// getAgentMethods returns auth methods backed by the SSH agent
+// This mimics what the standard ssh command does, allowed filters keys when
+// IdentitiesOnly is set (nil allows every key)
+func getAgentMethods(agentConn net.Conn, allowed map[string]bool) []ssh.AuthMethod {
+	if agentConn == nil {
+		return []ssh.AuthMethod{}
+	}
 
-		// Parse Host directive
-		if strings.HasPrefix(line, "Host ") {
-			currentHost = strings.TrimPrefix(line, "Host ")
-			hostMatches = matchSSHConfigPattern(currentHost, host)
-			continue
-		}
+	agentClient := agent.NewClient(agentConn)
+	signers, err := agentClient.Signers()
+	if err != nil || len(signers) == 0 {
+		return []ssh.AuthMethod{}
+	}
 
-		// If we're in a matching host block, look for IdentityFile
-		if hostMatches && strings.HasPrefix(line, "IdentityFile ") {
-			keyPath := strings.TrimPrefix(line, "IdentityFile ")
-			// Expand ~ to home directory
-			if strings.HasPrefix(keyPath, "~") {
-				keyPath = filepath.Join(home, keyPath[1:])
+	if allowed != nil {
+		var filtered []ssh.Signer
+		for _, signer := range signers {
+			if allowed[string(signer.PublicKey().Marshal())] {
+				filtered = append(filtered, signer)
 			}
-			keyPaths = append(keyPaths, keyPath)
 		}
+		if len(filtered) == 0 {
+			return []ssh.AuthMethod{}
+		}
+		signers = filtered
 	}
 
-	return keyPaths
+	return []ssh.AuthMethod{ssh.PublicKeys(signers...)}
 }
 
-// matchSSHConfigPattern checks if a host matches an SSH config pattern
-// Supports wildcards like "192.168.1.*" or "*.example.com"
-func matchSSHConfigPattern(pattern, host string) bool {
-	// Exact match
-	if pattern == host {
-		return true
-	}
-
-	// Wildcard matching
-	if strings.Contains(pattern, "*") {
-		// Simple wildcard matching (not full glob support)
-		pattern = strings.ReplaceAll(pattern, "*", ".*")
-		// Use simple string prefix/suffix matching for common patterns
-		if strings.HasPrefix(pattern, ".*") {
-			// Pattern like "*.example.com"
-			suffix := pattern[2:] // Remove ".*"
-			if strings.HasSuffix(host, suffix) {
-				return true
-			}
-		} else if strings.HasSuffix(pattern, ".*") {
-			// Pattern like "192.168.1.*"
-			prefix := pattern[:len(pattern)-2] // Remove ".*"
-			if strings.HasPrefix(host, prefix) {
-				return true
-			}
+// identityPublicKeys returns the public keys of the given identity files
+// Used to restrict agent keys when IdentitiesOnly is set
+func identityPublicKeys(paths []string) map[string]bool {
+	keys := make(map[string]bool)
+	for _, path := range paths {
+		expandedPath, err := expandPath(path)
+		if err != nil {
+			continue
+		}
+		if publicKey := loadPublicKey(expandedPath + ".pub"); publicKey != nil {
+			keys[string(publicKey.Marshal())] = true
+			continue
+		}
+		signer, err := loadPrivateKey(expandedPath)
+		if err == nil {
+			keys[string(signer.PublicKey().Marshal())] = true
+			continue
+		}
+		var missingErr *ssh.PassphraseMissingError
+		if errors.As(err, &missingErr) && missingErr.PublicKey != nil {
+			keys[string(missingErr.PublicKey.Marshal())] = true
 		}
 	}
-
-	return false
+	return keys
 }
 
-// getAgentMethods tries to connect to SSH agent and get auth methods
-// This mimics what the standard ssh command does
-func getAgentMethods() []ssh.AuthMethod {
-	sshAgentAddr := os.Getenv("SSH_AUTH_SOCK")
-	if sshAgentAddr == "" {
-		return []ssh.AuthMethod{}
-	}
-
-	conn, err := net.Dial("unix", sshAgentAddr)
-	if err != nil {
-		return []ssh.AuthMethod{}
-	}
-	defer conn.Close()
+// Create auth method chain (try agent, then the explicit key, then keys from
+// SSH config, then default keys, then password and keyboard-interactive)
+func createAuthMethods(e endpoint, agentConn net.Conn, opts ConnectOptions) ([]ssh.AuthMethod, error) {
+	var authMethods []ssh.AuthMethod
 
-	agentClient := agent.NewClient(conn)
-	signers, err := agentClient.Signers()
-	if err != nil || len(signers) == 0 {
-		return []ssh.AuthMethod{}
+	var keyPaths []string
+	if e.keyPath != "" {
+		keyPaths = append(keyPaths, e.keyPath)
 	}
-
-	return []ssh.AuthMethod{ssh.PublicKeys(signers...)}
-}
-
-// Create auth method chain (try agent, then keys from SSH config, then default keys)
-func createAuthMethods(keyPath string, host string) ([]ssh.AuthMethod, error) {
-	var authMethods []ssh.AuthMethod
+	keyPaths = append(keyPaths, e.identityFiles...)
 
 	// Try SSH agent first (this is what standard ssh command does)
-	agentMethods := getAgentMethods()
-	authMethods = append(authMethods, agentMethods...)
+	var allowed map[string]bool
+	if e.identitiesOnly {
+		allowed = identityPublicKeys(keyPaths)
+	}
+	authMethods = append(authMethods, getAgentMethods(agentConn, allowed)...)
 
-	// Try specified key path
-	if keyPath != "" {
-		signer, err := loadPrivateKey(keyPath)
-		if err == nil {
-			authMethods = append(authMethods, ssh.PublicKeys(signer))
-		}
+	// Default keys are only used when IdentitiesOnly doesn't forbid it
+	if !e.identitiesOnly {
+		keyPaths = append(keyPaths, getDefaultKeyPaths()...)
 	}
 
-	// Try keys from SSH config (matches against host)
-	for _, path := range getSSHConfigKeyPaths(host) {
-		signer, err := loadPrivateKey(path)
-		if err == nil {
-			authMethods = append(authMethods, ssh.PublicKeys(signer))
+	// Try specified key path, keys from SSH config, then default key paths
+	seen := make(map[string]bool)
+	for _, path := range keyPaths {
+		if seen[path] {
+			continue
 		}
-	}
+		seen[path] = true
 
-	// Try default key paths
-	for _, path := range getDefaultKeyPaths() {
-		signer, err := loadPrivateKey(path)
+		method, err := loadKeyAuthMethod(path, e, opts)
 		if err == nil {
-			authMethods = append(authMethods, ssh.PublicKeys(signer))
+			authMethods = append(authMethods, method)
 		}
 	}
 
-	// TODO: Add password auth method if needed
+	// Try password and keyboard-interactive last
+	authMethods = append(authMethods, getPasswordMethods(e, opts)...)
 
 	if len(authMethods) == 0 {
 		return nil, fmt.Errorf("no valid authentication methods found")
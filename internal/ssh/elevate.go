@@ -0,0 +1,52 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ReadFileElevated reads a remote file through an elevation command such as "sudo -n"
+// Used for files the login user can't read over sftp
+func (s *SSHClientWrapper) ReadFileElevated(ctx context.Context, elevate, name string) ([]byte, error) {
+	var stdout bytes.Buffer
+	cmd := fmt.Sprintf("%s cat -- %s", elevate, ShellQuote(name))
+	if err := s.runElevated(ctx, cmd, nil, &stdout); err != nil {
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// WriteFileElevated replaces the contents of a remote file through an elevation command
+// The file is rewritten in place by tee, so its owner, group and mode bits are kept
+func (s *SSHClientWrapper) WriteFileElevated(ctx context.Context, elevate, name string, data []byte) error {
+	cmd := fmt.Sprintf("%s tee -- %s >/dev/null", elevate, ShellQuote(name))
+	return s.runElevated(ctx, cmd, bytes.NewReader(data), io.Discard)
+}
+
+// runElevated runs an elevation command, a non-zero exit becomes an error carrying its stderr
+func (s *SSHClientWrapper) runElevated(ctx context.Context, cmd string, stdin io.Reader, stdout io.Writer) error {
+	var stderr bytes.Buffer
+	exitCode, runErr, err := s.runCommand(ctx, cmd, stdin, stdout, &stderr)
+	if err != nil {
+		return err
+	}
+	if runErr != nil {
+		return runErr
+	}
+	if exitCode != 0 {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = fmt.Sprintf("exit status %d", exitCode)
+		}
+		return fmt.Errorf("%s failed: %s", cmd, msg)
+	}
+	return nil
+}
+
+// ShellQuote quotes s as a single word for a POSIX shell
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,63 @@
+package ssh
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// OutputChunk is a piece of remote output, delivered as soon as it arrives
+type OutputChunk struct {
+	Stderr bool
+	Data   []byte
+}
+
+// OutputFunc receives output chunks of a streaming command
+// Calls are serialized, in the order the chunks arrived
+type OutputFunc func(chunk OutputChunk)
+
+// cancelGracePeriod is how long a cancelled command gets to exit after each signal
+const cancelGracePeriod = 3 * time.Second
+
+// ExecuteCommandStream runs a command and passes its output to onOutput while it runs
+// The returned result carries the exit code and duration, its Stdout and Stderr are
+// left empty since the output was already delivered
+// When ctx is done the command is interrupted, see ExecuteCommandContext
+// This is a blocking call - should be wrapped in a goroutine by the caller
+func (s *SSHClientWrapper) ExecuteCommandStream(ctx context.Context, cmd string, onOutput OutputFunc) (*CommandResult, error) {
+	start := time.Now()
+
+	// One lock for both streams keeps chunks in arrival order
+	var mu sync.Mutex
+	stdout := &chunkWriter{mu: &mu, onOutput: onOutput}
+	stderr := &chunkWriter{mu: &mu, onOutput: onOutput, stderr: true}
+
+	exitCode, runErr, err := s.runCommand(ctx, cmd, nil, stdout, stderr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CommandResult{
+		ExitCode: exitCode,
+		Duration: time.Since(start),
+		Error:    runErr,
+	}, nil
+}
+
+// chunkWriter forwards each write of a session stream as an OutputChunk
+type chunkWriter struct {
+	mu       *sync.Mutex
+	onOutput OutputFunc
+	stderr   bool
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	// The session reuses p, so hand out a copy
+	data := make([]byte, len(p))
+	copy(data, p)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onOutput(OutputChunk{Stderr: w.stderr, Data: data})
+	return len(p), nil
+}
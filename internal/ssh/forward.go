@@ -0,0 +1,373 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ForwardKind is the direction of a port forward, named after the ssh flags
+type ForwardKind int
+
+const (
+	ForwardLocal   ForwardKind = iota // -L: local port to a host reachable from the server
+	ForwardRemote                     // -R: server port to a host reachable from here
+	ForwardDynamic                    // -D: local SOCKS5 proxy through the server
+)
+
+// String returns the ssh flag letter for the kind
+func (k ForwardKind) String() string {
+	switch k {
+	case ForwardLocal:
+		return "L"
+	case ForwardRemote:
+		return "R"
+	case ForwardDynamic:
+		return "D"
+	default:
+		return "?"
+	}
+}
+
+// ForwardSpec describes a port forward
+type ForwardSpec struct {
+	Kind        ForwardKind
+	BindAddress string // Listen address, empty for the default (localhost)
+	Port        int    // Listen port
+	TargetHost  string // Where connections go, unused for dynamic forwards
+	TargetPort  int
+}
+
+// ParseForwardSpec parses a forward the way ssh takes it on the command line:
+// "L [bind:]port:host:hostport", "R [bind:]port:host:hostport" or "D [bind:]port"
+// The kind may also be written as a flag, e.g. "-L 8080:db:5432"
+func ParseForwardSpec(spec string) (ForwardSpec, error) {
+	kind, rest, found := strings.Cut(strings.TrimSpace(spec), " ")
+	if !found {
+		return ForwardSpec{}, fmt.Errorf("expected \"L|R|D <forward>\", got %q", spec)
+	}
+
+	var f ForwardSpec
+	switch strings.ToUpper(strings.TrimPrefix(kind, "-")) {
+	case "L":
+		f.Kind = ForwardLocal
+	case "R":
+		f.Kind = ForwardRemote
+	case "D":
+		f.Kind = ForwardDynamic
+	default:
+		return ForwardSpec{}, fmt.Errorf("unknown forward type %q, use L, R or D", kind)
+	}
+
+	fields := splitForwardFields(strings.TrimSpace(rest))
+	want := 3
+	if f.Kind == ForwardDynamic {
+		want = 1
+	}
+	switch len(fields) {
+	case want:
+	case want + 1:
+		f.BindAddress = fields[0]
+		fields = fields[1:]
+	default:
+		return ForwardSpec{}, fmt.Errorf("invalid %s forward %q", f.Kind, rest)
+	}
+
+	port, err := parseForwardPort(fields[0])
+	if err != nil {
+		return ForwardSpec{}, err
+	}
+	f.Port = port
+
+	if f.Kind != ForwardDynamic {
+		f.TargetHost = fields[1]
+		if f.TargetHost == "" {
+			return ForwardSpec{}, fmt.Errorf("missing target host in %q", rest)
+		}
+		if f.TargetPort, err = parseForwardPort(fields[2]); err != nil {
+			return ForwardSpec{}, err
+		}
+	}
+	return f, nil
+}
+
+// splitForwardFields splits on colons, keeping bracketed IPv6 addresses whole
+func splitForwardFields(s string) []string {
+	var fields []string
+	var current strings.Builder
+	bracketed := false
+	for _, r := range s {
+		switch {
+		case r == '[':
+			bracketed = true
+		case r == ']':
+			bracketed = false
+		case r == ':' && !bracketed:
+			fields = append(fields, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	return append(fields, current.String())
+}
+
+// parseForwardPort parses a port number of a forward spec
+func parseForwardPort(s string) (int, error) {
+	port, err := strconv.Atoi(s)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return port, nil
+}
+
+// String returns the spec in the form ParseForwardSpec accepts
+func (f ForwardSpec) String() string {
+	listen := strconv.Itoa(f.Port)
+	if f.BindAddress != "" {
+		listen = bracketHost(f.BindAddress) + ":" + listen
+	}
+	if f.Kind == ForwardDynamic {
+		return fmt.Sprintf("%s %s", f.Kind, listen)
+	}
+	return fmt.Sprintf("%s %s:%s:%d", f.Kind, listen, bracketHost(f.TargetHost), f.TargetPort)
+}
+
+// bracketHost wraps IPv6 addresses in brackets for host:port strings
+func bracketHost(host string) string {
+	if strings.Contains(host, ":") {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+// listenAddress returns where the forward listens
+// Like ssh, local listeners bind to localhost unless told otherwise
+func (f ForwardSpec) listenAddress() string {
+	bind := f.BindAddress
+	switch {
+	case bind == "*":
+		bind = ""
+	case bind == "" && f.Kind != ForwardRemote:
+		bind = "localhost"
+	}
+	return net.JoinHostPort(bind, strconv.Itoa(f.Port))
+}
+
+// targetAddress returns where forwarded connections go
+func (f ForwardSpec) targetAddress() string {
+	return net.JoinHostPort(f.TargetHost, strconv.Itoa(f.TargetPort))
+}
+
+// ForwardStats is a snapshot of a forward's traffic
+type ForwardStats struct {
+	BytesSent     int64 // Towards the target
+	BytesReceived int64 // Back from the target
+	Active        int64 // Connections currently open
+	Total         int64 // Connections handled so far
+	LastError     error // Most recent connection failure
+	Closed        bool  // Whether the forward has stopped listening
+}
+
+// Forward is a running port forward on a connection
+type Forward struct {
+	Spec ForwardSpec
+
+	listener net.Listener
+	dial     func(address string) (net.Conn, error)
+
+	sent     atomic.Int64
+	received atomic.Int64
+	active   atomic.Int64
+	total    atomic.Int64
+	closed   atomic.Bool
+
+	mu      sync.Mutex
+	lastErr error
+}
+
+// StartForward starts listening for a forward and serves it until closed
+// Forwards are closed along with the connection
+func (s *SSHClientWrapper) StartForward(spec ForwardSpec) (*Forward, error) {
+	if !s.connected.Load() || s.client == nil {
+		return nil, fmt.Errorf("not connected to server")
+	}
+
+	f := &Forward{Spec: spec}
+	var err error
+	switch spec.Kind {
+	case ForwardLocal, ForwardDynamic:
+		f.listener, err = net.Listen("tcp", spec.listenAddress())
+		f.dial = func(address string) (net.Conn, error) {
+			return s.client.Dial("tcp", address)
+		}
+	case ForwardRemote:
+		// Asks the server to listen (tcpip-forward) and hand us its connections
+		f.listener, err = s.client.Listen("tcp", spec.listenAddress())
+		f.dial = func(address string) (net.Conn, error) {
+			return net.Dial("tcp", address)
+		}
+	default:
+		return nil, fmt.Errorf("unknown forward type %v", spec.Kind)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", spec.listenAddress(), err)
+	}
+
+	s.forwardsMu.Lock()
+	// Drop forwards closed since the last start so the list doesn't grow
+	running := s.forwards[:0]
+	for _, existing := range s.forwards {
+		if !existing.closed.Load() {
+			running = append(running, existing)
+		}
+	}
+	s.forwards = append(running, f)
+	s.forwardsMu.Unlock()
+
+	go f.serve()
+	return f, nil
+}
+
+// closeForwards stops every forward of the connection
+func (s *SSHClientWrapper) closeForwards() {
+	s.forwardsMu.Lock()
+	forwards := s.forwards
+	s.forwards = nil
+	s.forwardsMu.Unlock()
+
+	for _, f := range forwards {
+		f.Close()
+	}
+}
+
+// serve accepts connections until the listener is closed
+func (f *Forward) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			if !f.closed.Load() {
+				f.setError(err)
+				f.closed.Store(true)
+			}
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+// handle forwards one accepted connection
+func (f *Forward) handle(conn net.Conn) {
+	defer conn.Close()
+	f.total.Add(1)
+	f.active.Add(1)
+	defer f.active.Add(-1)
+
+	target := f.Spec.targetAddress()
+	if f.Spec.Kind == ForwardDynamic {
+		var err error
+		target, err = socksHandshake(conn)
+		if err != nil {
+			f.setError(err)
+			return
+		}
+	}
+
+	remote, err := f.dial(target)
+	if err != nil {
+		f.setError(fmt.Errorf("failed to connect to %s: %w", target, err))
+		if f.Spec.Kind == ForwardDynamic {
+			_ = socksReply(conn, socksReplyFor(err))
+		}
+		return
+	}
+	defer remote.Close()
+
+	if f.Spec.Kind == ForwardDynamic {
+		if err := socksReply(conn, socksSucceeded); err != nil {
+			return
+		}
+	}
+
+	f.pipe(conn, remote)
+}
+
+// pipe copies both ways until either side is done, counting bytes as they pass
+func (f *Forward) pipe(local, remote net.Conn) {
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(countingWriter{remote, &f.sent}, local)
+		closeWrite(remote)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(countingWriter{local, &f.received}, remote)
+		closeWrite(local)
+		done <- struct{}{}
+	}()
+	<-done
+	<-done
+}
+
+// countingWriter adds every write to a counter, so long-lived connections
+// show their traffic before they close
+type countingWriter struct {
+	w io.Writer
+	n *atomic.Int64
+}
+
+// Write passes p on and counts what was written
+func (c countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// closeWrite half-closes a connection so the other side sees EOF
+func closeWrite(conn net.Conn) {
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+		_ = cw.CloseWrite()
+		return
+	}
+	conn.Close()
+}
+
+// setError records the most recent failure
+func (f *Forward) setError(err error) {
+	f.mu.Lock()
+	f.lastErr = err
+	f.mu.Unlock()
+}
+
+// Stats returns a snapshot of the forward's traffic
+func (f *Forward) Stats() ForwardStats {
+	f.mu.Lock()
+	lastErr := f.lastErr
+	f.mu.Unlock()
+
+	return ForwardStats{
+		BytesSent:     f.sent.Load(),
+		BytesReceived: f.received.Load(),
+		Active:        f.active.Load(),
+		Total:         f.total.Load(),
+		LastError:     lastErr,
+		Closed:        f.closed.Load(),
+	}
+}
+
+// Close stops listening, connections already open run until they finish
+func (f *Forward) Close() error {
+	if f.closed.Swap(true) {
+		return nil
+	}
+	err := f.listener.Close()
+	if errors.Is(err, net.ErrClosed) || errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
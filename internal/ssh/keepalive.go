@@ -0,0 +1,122 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	defaultKeepaliveInterval  = 15 * time.Second
+	defaultKeepaliveMaxMisses = 3
+)
+
+// keepaliveRequest is the global request OpenSSH uses for ServerAliveInterval
+// Servers answer it even when they don't support it, which is all we need
+const keepaliveRequest = "keepalive@openssh.com"
+
+// KeepaliveOptions controls how a dead connection is detected
+// Zero values use the defaults (every 15s, dead after 3 misses)
+type KeepaliveOptions struct {
+	Interval  time.Duration // Time between keepalive requests
+	MaxMisses int           // Unanswered requests in a row before the link is considered dead
+}
+
+// DeadFunc is called once when a connection is lost without Disconnect being called
+type DeadFunc func(err error)
+
+// StartKeepalive probes the connection in the background until it is closed
+// When the server stops answering, or the transport drops, the connection is
+// closed, IsConnected turns false and onDead is called from the background goroutine
+func (s *SSHClientWrapper) StartKeepalive(opts KeepaliveOptions, onDead DeadFunc) {
+	if opts.Interval <= 0 {
+		opts.Interval = defaultKeepaliveInterval
+	}
+	if opts.MaxMisses <= 0 {
+		opts.MaxMisses = defaultKeepaliveMaxMisses
+	}
+
+	var once sync.Once
+	dead := func(err error) {
+		// Swap makes sure a deliberate Disconnect never reports a dead link
+		if !s.connected.Swap(false) {
+			return
+		}
+		s.closeForwards()
+		s.client.Close()
+		closeClients(s.jumps)
+		once.Do(func() { onDead(err) })
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		err := s.client.Wait()
+		close(closed)
+		if err == nil {
+			err = fmt.Errorf("connection closed by remote host")
+		}
+		dead(fmt.Errorf("connection lost: %w", err))
+	}()
+
+	go func() {
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+
+		misses := 0
+		for {
+			select {
+			case <-closed:
+				return
+			case <-ticker.C:
+			}
+
+			if s.sendKeepalive(opts.Interval) {
+				misses = 0
+				continue
+			}
+
+			misses++
+			if misses >= opts.MaxMisses {
+				dead(fmt.Errorf("connection lost: no keepalive response after %d attempts", misses))
+				return
+			}
+		}
+	}()
+}
+
+// sendKeepalive sends one keepalive request, reporting whether a reply arrived within timeout
+func (s *SSHClientWrapper) sendKeepalive(timeout time.Duration) bool {
+	reply := make(chan error, 1)
+	go func() {
+		// A "request failed" reply still proves the server is alive
+		_, _, err := s.client.SendRequest(keepaliveRequest, true, nil)
+		reply <- err
+	}()
+
+	select {
+	case err := <-reply:
+		return err == nil
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// IsConnectionError reports whether a command failed because the connection
+// itself is gone, rather than because the server refused a session on a
+// healthy one, e.g. MaxSessions or "administratively prohibited"
+// Ambiguous failures are left to the keepalive loop to detect
+func IsConnectionError(err error) bool {
+	var openErr *ssh.OpenChannelError
+	if errors.As(err, &openErr) {
+		return false
+	}
+	var netErr net.Error
+	return errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) || errors.As(err, &netErr)
+}
@@ -0,0 +1,218 @@
+package ssh
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// maxPassphraseAttempts is how often the user may retry a wrong passphrase
+const maxPassphraseAttempts = 3
+
+// signerCache holds decrypted keys for the lifetime of the process only
+// Keyed by expanded key path, nothing here is ever written to disk
+var (
+	signerCacheMu sync.Mutex
+	signerCache   = make(map[string]ssh.Signer)
+)
+
+// cachedSigner returns a previously decrypted key, if any
+func cachedSigner(path string) (ssh.Signer, bool) {
+	signerCacheMu.Lock()
+	defer signerCacheMu.Unlock()
+	signer, ok := signerCache[path]
+	return signer, ok
+}
+
+// cacheSigner remembers a decrypted key for later connections
+func cacheSigner(path string, signer ssh.Signer) {
+	signerCacheMu.Lock()
+	defer signerCacheMu.Unlock()
+	signerCache[path] = signer
+}
+
+// loadKeyAuthMethod returns a public key auth method for the key at keyPath
+// Passphrase-protected keys are decrypted on demand by asking the user
+func loadKeyAuthMethod(keyPath string, e endpoint, opts ConnectOptions) (ssh.AuthMethod, error) {
+	expandedPath, err := expandPath(keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if signer, ok := cachedSigner(expandedPath); ok {
+		return ssh.PublicKeys(signer), nil
+	}
+
+	signer, err := loadPrivateKey(expandedPath)
+	if err == nil {
+		return ssh.PublicKeys(signer), nil
+	}
+
+	var missingErr *ssh.PassphraseMissingError
+	if !errors.As(err, &missingErr) || opts.PromptSecret == nil {
+		return nil, err
+	}
+
+	keyData, err := os.ReadFile(expandedPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %v", err)
+	}
+
+	locked := &lockedKey{
+		path:    expandedPath,
+		keyData: keyData,
+		prompt: SecretPrompt{
+			Kind:     PromptPassphrase,
+			User:     e.user,
+			Host:     e.hostname,
+			Jump:     e.jump,
+			KeyPath:  keyPath,
+			Question: fmt.Sprintf("Enter passphrase for key '%s': ", keyPath),
+		},
+		promptFunc: opts.PromptSecret,
+	}
+
+	// With the public key at hand we only ask for the passphrase once the
+	// server has accepted the key, just like OpenSSH does
+	publicKey := missingErr.PublicKey
+	if publicKey == nil {
+		publicKey = loadPublicKey(expandedPath + ".pub")
+	}
+	if publicKey != nil {
+		return ssh.PublicKeys(&lazySigner{key: locked, publicKey: publicKey}), nil
+	}
+
+	// Legacy PEM keys don't carry a public key, decrypt when publickey auth starts
+	return ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+		signer, err := locked.unlock()
+		if err != nil {
+			return nil, err
+		}
+		return []ssh.Signer{signer}, nil
+	}), nil
+}
+
+// loadPublicKey reads an authorized_keys style .pub file, returns nil on failure
+func loadPublicKey(path string) ssh.PublicKey {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil
+	}
+	return publicKey
+}
+
+// lockedKey is an encrypted private key waiting for its passphrase
+type lockedKey struct {
+	path       string
+	keyData    []byte
+	prompt     SecretPrompt
+	promptFunc SecretPromptFunc
+
+	mu     sync.Mutex
+	signer ssh.Signer
+}
+
+// unlock asks for the passphrase and decrypts the key, retrying on typos
+func (k *lockedKey) unlock() (ssh.Signer, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.signer != nil {
+		return k.signer, nil
+	}
+	// Another connection may have decrypted the same file in the meantime
+	if signer, ok := cachedSigner(k.path); ok {
+		k.signer = signer
+		return signer, nil
+	}
+
+	for attempt := 1; attempt <= maxPassphraseAttempts; attempt++ {
+		passphrase, err := k.promptFunc(k.prompt)
+		if err != nil {
+			return nil, err
+		}
+
+		signer, err := ssh.ParsePrivateKeyWithPassphrase(k.keyData, []byte(passphrase))
+		if err == nil {
+			k.signer = signer
+			cacheSigner(k.path, signer)
+			return signer, nil
+		}
+		if !errors.Is(err, x509.IncorrectPasswordError) {
+			return nil, fmt.Errorf("failed to decrypt private key: %v", err)
+		}
+		k.prompt.Instruction = "Incorrect passphrase, try again."
+	}
+	return nil, fmt.Errorf("too many incorrect passphrases for %s", k.prompt.KeyPath)
+}
+
+// lazySigner exposes the public half of an encrypted key and only decrypts
+// the private half when the server actually asks for a signature
+type lazySigner struct {
+	key       *lockedKey
+	publicKey ssh.PublicKey
+}
+
+// PublicKey returns the public key without needing the passphrase
+func (s *lazySigner) PublicKey() ssh.PublicKey {
+	return s.publicKey
+}
+
+// Sign decrypts the key if needed and signs data
+func (s *lazySigner) Sign(rand io.Reader, data []byte) (*ssh.Signature, error) {
+	signer, err := s.key.unlock()
+	if err != nil {
+		return nil, err
+	}
+	return signer.Sign(rand, data)
+}
+
+// SignWithAlgorithm lets RSA keys negotiate SHA-2 signatures
+func (s *lazySigner) SignWithAlgorithm(rand io.Reader, data []byte, algorithm string) (*ssh.Signature, error) {
+	signer, err := s.key.unlock()
+	if err != nil {
+		return nil, err
+	}
+	if algorithmSigner, ok := signer.(ssh.AlgorithmSigner); ok {
+		return algorithmSigner.SignWithAlgorithm(rand, data, algorithm)
+	}
+	if algorithm != "" && algorithm != signer.PublicKey().Type() {
+		return nil, fmt.Errorf("key does not support signature algorithm %s", algorithm)
+	}
+	return signer.Sign(rand, data)
+}
+
+// CheckKeyFile reports why the private key at keyPath can't be used, nil if it can be read
+func CheckKeyFile(keyPath string) error {
+	expandedPath, err := expandPath(keyPath)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(expandedPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%s does not exist", keyPath)
+		}
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", keyPath)
+	}
+	f, err := os.Open(expandedPath)
+	if err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			return fmt.Errorf("%s is not readable", keyPath)
+		}
+		return err
+	}
+	return f.Close()
+}
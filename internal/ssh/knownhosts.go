@@ -0,0 +1,226 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// globalKnownHostsFile is the system-wide known_hosts file consulted by OpenSSH
+const globalKnownHostsFile = "/etc/ssh/ssh_known_hosts"
+
+// HostKeyDecision is the user's answer to an unknown host key
+type HostKeyDecision int
+
+const (
+	HostKeyReject HostKeyDecision = iota
+	HostKeyAcceptOnce
+	HostKeyAcceptAndSave
+)
+
+// HostKeyRequest describes an unknown host key awaiting confirmation
+type HostKeyRequest struct {
+	Hostname    string // Address as dialed (host:port)
+	KeyType     string // e.g. ssh-ed25519
+	Fingerprint string // SHA256 fingerprint of the key
+}
+
+// HostKeyConfirmFunc asks the user whether to trust an unknown host key
+// It is called from the connecting goroutine and may block until answered
+type HostKeyConfirmFunc func(req HostKeyRequest) HostKeyDecision
+
+// defaultKnownHostsFile returns the path to ~/.ssh/known_hosts
+func defaultKnownHostsFile() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %v", err)
+	}
+	return filepath.Join(home, ".ssh", "known_hosts"), nil
+}
+
+// knownHostsFiles returns the known_hosts files to check for a connection
+// A custom file replaces the user's ~/.ssh/known_hosts, the global file is always read
+func knownHostsFiles(customPath string) ([]string, error) {
+	userFile := customPath
+	if userFile == "" {
+		path, err := defaultKnownHostsFile()
+		if err != nil {
+			return nil, err
+		}
+		userFile = path
+	}
+
+	userFile, err := expandPath(userFile)
+	if err != nil {
+		return nil, err
+	}
+	return []string{userFile, globalKnownHostsFile}, nil
+}
+
+// hostKeyVerifier checks host keys against known_hosts for a single connection
+type hostKeyVerifier struct {
+	userFile string              // Writable known_hosts file, accepted keys are saved here
+	lookup   ssh.HostKeyCallback // Raw known_hosts lookup without prompting
+	confirm  HostKeyConfirmFunc  // Asked about unknown hosts, nil rejects them
+}
+
+// newHostKeyVerifier loads known_hosts for a connection
+// Hashed entries and @cert-authority / @revoked markers are handled by knownhosts
+func newHostKeyVerifier(customPath string, confirm HostKeyConfirmFunc) (*hostKeyVerifier, error) {
+	files, err := knownHostsFiles(customPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Missing files are treated as empty, every host is then simply unknown
+	var existing []string
+	for _, file := range files {
+		if _, err := os.Stat(file); err == nil {
+			existing = append(existing, file)
+		}
+	}
+
+	lookup, err := knownhosts.New(existing...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known_hosts: %v", err)
+	}
+
+	return &hostKeyVerifier{
+		userFile: files[0],
+		lookup:   lookup,
+		confirm:  confirm,
+	}, nil
+}
+
+// check is the ssh.HostKeyCallback for the connection
+// Unknown hosts are passed to confirm, mismatched and revoked keys never are
+func (v *hostKeyVerifier) check(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	err := v.lookup(hostname, remote, key)
+	if v.confirm == nil || !isUnknownHostError(err) {
+		return describeHostKeyError(hostname, key, err)
+	}
+
+	decision := v.confirm(HostKeyRequest{
+		Hostname:    hostname,
+		KeyType:     key.Type(),
+		Fingerprint: ssh.FingerprintSHA256(key),
+	})
+	switch decision {
+	case HostKeyAcceptOnce:
+		return nil
+	case HostKeyAcceptAndSave:
+		if err := appendKnownHost(v.userFile, hostname, key); err != nil {
+			return fmt.Errorf("host key accepted but not saved: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("host key for %s rejected by user", hostname)
+	}
+}
+
+// appendKnownHost records a host key at the end of a known_hosts file
+func appendKnownHost(path string, hostname string, key ssh.PublicKey) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create known_hosts directory: %v", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open known_hosts: %v", err)
+	}
+	defer file.Close()
+
+	line := knownhosts.Line([]string{hostname}, key) + "\n"
+	if data, err := os.ReadFile(path); err == nil && len(data) > 0 && data[len(data)-1] != '\n' {
+		// Don't glue our entry onto an unterminated last line
+		line = "\n" + line
+	}
+	if _, err := file.WriteString(line); err != nil {
+		return fmt.Errorf("failed to write known_hosts: %v", err)
+	}
+	return nil
+}
+
+// describeHostKeyError turns knownhosts errors into messages fit for the UI
+// The original error stays wrapped so callers can still inspect it with errors.As
+func describeHostKeyError(hostname string, key ssh.PublicKey, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var revokedErr *knownhosts.RevokedError
+	if errors.As(err, &revokedErr) {
+		return fmt.Errorf("host key for %s is revoked (%s:%d): %w",
+			hostname, revokedErr.Revoked.Filename, revokedErr.Revoked.Line, err)
+	}
+
+	var keyErr *knownhosts.KeyError
+	if errors.As(err, &keyErr) {
+		if len(keyErr.Want) == 0 {
+			return fmt.Errorf("host %s is not in known_hosts (%s %s): %w",
+				hostname, key.Type(), ssh.FingerprintSHA256(key), err)
+		}
+		known := keyErr.Want[0]
+		return fmt.Errorf("HOST KEY MISMATCH for %s: server sent %s %s, expected key at %s:%d - possible man-in-the-middle attack: %w",
+			hostname, key.Type(), ssh.FingerprintSHA256(key), known.Filename, known.Line, err)
+	}
+
+	return fmt.Errorf("host key verification failed for %s: %w", hostname, err)
+}
+
+// isUnknownHostError reports whether err means the host has no known_hosts entry
+func isUnknownHostError(err error) bool {
+	var keyErr *knownhosts.KeyError
+	return errors.As(err, &keyErr) && len(keyErr.Want) == 0
+}
+
+// algorithms orders the host key algorithms so the server is asked for a
+// key type we already have on file, avoiding false mismatches on multi-key hosts
+func (v *hostKeyVerifier) algorithms(address string) []string {
+	// Probe the database with a throwaway key to learn which key types are known
+	probe, err := probeKey()
+	if err != nil {
+		return nil
+	}
+
+	err = v.lookup(address, &net.TCPAddr{IP: net.IPv4zero}, probe)
+	var keyErr *knownhosts.KeyError
+	if !errors.As(err, &keyErr) || len(keyErr.Want) == 0 {
+		return nil
+	}
+
+	var preferred []string
+	seen := make(map[string]bool)
+	add := func(algo string) {
+		if !seen[algo] {
+			seen[algo] = true
+			preferred = append(preferred, algo)
+		}
+	}
+
+	for _, known := range keyErr.Want {
+		if known.Key.Type() == ssh.KeyAlgoRSA {
+			// RSA keys are negotiated with SHA-2 signatures first
+			add(ssh.KeyAlgoRSASHA512)
+			add(ssh.KeyAlgoRSASHA256)
+		}
+		add(known.Key.Type())
+	}
+
+	// Keep every other supported algorithm as a fallback
+	for _, algo := range ssh.SupportedAlgorithms().HostKeys {
+		add(algo)
+	}
+	return preferred
+}
+
+// probeKey returns a fixed public key that will never be in known_hosts
+func probeKey() (ssh.PublicKey, error) {
+	return ssh.NewPublicKey(ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)))
+}
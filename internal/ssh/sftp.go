@@ -0,0 +1,316 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/pkg/sftp"
+)
+
+// FileInfo describes one entry of a local or remote directory listing
+type FileInfo struct {
+	Name    string
+	Size    int64
+	Mode    os.FileMode
+	ModTime time.Time
+	IsDir   bool
+}
+
+// NewFileInfo converts an os.FileInfo for listings
+func NewFileInfo(fi os.FileInfo) FileInfo {
+	return FileInfo{
+		Name:    fi.Name(),
+		Size:    fi.Size(),
+		Mode:    fi.Mode(),
+		ModTime: fi.ModTime(),
+		IsDir:   fi.IsDir(),
+	}
+}
+
+// SortFileInfos orders a listing with directories first, then by name
+func SortFileInfos(entries []FileInfo) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].IsDir != entries[j].IsDir {
+			return entries[i].IsDir
+		}
+		return entries[i].Name < entries[j].Name
+	})
+}
+
+// ProgressFunc is called as a transfer makes progress
+// total is -1 when the size isn't known up front
+type ProgressFunc func(done, total int64)
+
+// SFTPClient is an SFTP session on an existing connection
+// Closing it ends the subsystem but keeps the connection open
+type SFTPClient struct {
+	client *sftp.Client
+}
+
+// OpenSFTP starts the sftp subsystem on the connection
+func (s *SSHClientWrapper) OpenSFTP() (*SFTPClient, error) {
+	if !s.connected.Load() || s.client == nil {
+		return nil, fmt.Errorf("not connected to server")
+	}
+
+	client, err := sftp.NewClient(s.client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start sftp: %w", err)
+	}
+	return &SFTPClient{client: client}, nil
+}
+
+// Close ends the sftp session, the underlying connection stays open
+func (c *SFTPClient) Close() error {
+	return c.client.Close()
+}
+
+// Getwd returns the remote working directory, usually the user's home
+func (c *SFTPClient) Getwd() (string, error) {
+	return c.client.Getwd()
+}
+
+// ReadDir lists a remote directory, directories first
+func (c *SFTPClient) ReadDir(dir string) ([]FileInfo, error) {
+	infos, err := c.client.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]FileInfo, 0, len(infos))
+	for _, fi := range infos {
+		entries = append(entries, NewFileInfo(fi))
+	}
+	SortFileInfos(entries)
+	return entries, nil
+}
+
+// Stat returns the metadata of a remote file, following symlinks
+func (c *SFTPClient) Stat(name string) (FileInfo, error) {
+	fi, err := c.client.Stat(name)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return NewFileInfo(fi), nil
+}
+
+// Rename moves a remote file or directory
+func (c *SFTPClient) Rename(oldname, newname string) error {
+	return c.client.Rename(oldname, newname)
+}
+
+// Remove deletes a remote file or empty directory
+func (c *SFTPClient) Remove(name string) error {
+	return c.client.Remove(name)
+}
+
+// Mkdir creates a remote directory
+func (c *SFTPClient) Mkdir(name string) error {
+	return c.client.Mkdir(name)
+}
+
+// ReadFile reads a whole remote file along with its metadata
+func (c *SFTPClient) ReadFile(name string) ([]byte, FileInfo, error) {
+	f, err := c.client.Open(name)
+	if err != nil {
+		return nil, FileInfo{}, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, FileInfo{}, err
+	}
+	if fi.IsDir() {
+		return nil, FileInfo{}, fmt.Errorf("%s is a directory", name)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, FileInfo{}, err
+	}
+	return data, NewFileInfo(fi), nil
+}
+
+// WriteFile replaces the contents of a remote file and restores its mode bits
+// The data is written to a temporary file renamed over the original, so a
+// failed write leaves the original intact. Symlinks, files whose owner can't
+// be kept and servers refusing the rename fall back to truncating in place
+func (c *SFTPClient) WriteFile(name string, data []byte, perm os.FileMode) error {
+	fi, err := c.client.Lstat(name)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if fi != nil && fi.Mode()&os.ModeSymlink != 0 {
+		return c.writeInPlace(name, data, perm)
+	}
+
+	tmp := path.Join(path.Dir(name), fmt.Sprintf(".%s.beacon-%d", path.Base(name), time.Now().UnixNano()))
+	f, err := c.client.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		// Usually a directory we can't write to, the file itself may still be writable
+		return c.writeInPlace(name, data, perm)
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = c.client.Remove(tmp)
+		return err
+	}
+	// Best effort, some servers refuse to change permissions
+	_ = c.client.Chmod(tmp, perm)
+
+	if fi != nil && !c.keepOwner(tmp, fi) {
+		_ = c.client.Remove(tmp)
+		return c.writeInPlace(name, data, perm)
+	}
+	if err := c.client.PosixRename(tmp, name); err != nil {
+		_ = c.client.Remove(tmp)
+		return c.writeInPlace(name, data, perm)
+	}
+	return nil
+}
+
+// keepOwner gives tmp the owner and group of the file it replaces,
+// reporting whether they now match
+func (c *SFTPClient) keepOwner(tmp string, orig os.FileInfo) bool {
+	want, ok := orig.Sys().(*sftp.FileStat)
+	if !ok {
+		return true
+	}
+	fi, err := c.client.Stat(tmp)
+	if err != nil {
+		return false
+	}
+	got, ok := fi.Sys().(*sftp.FileStat)
+	if !ok || (got.UID == want.UID && got.GID == want.GID) {
+		return true
+	}
+	return c.client.Chown(tmp, int(want.UID), int(want.GID)) == nil
+}
+
+// writeInPlace truncates the remote file and writes data to it, keeping its
+// owner and group but leaving it truncated if the write fails
+func (c *SFTPClient) writeInPlace(name string, data []byte, perm os.FileMode) error {
+	f, err := c.client.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	// Best effort, only the owner may chmod and the bits usually survive the truncate
+	_ = c.client.Chmod(name, perm)
+	return nil
+}
+
+// Join joins remote path elements, remote paths always use forward slashes
+func (c *SFTPClient) Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+// Upload copies a local file to remotePath, replacing it if it exists
+// A cancelled transfer leaves a partial file behind and returns ctx.Err()
+func (c *SFTPClient) Upload(ctx context.Context, localPath, remotePath string, progress ProgressFunc) error {
+	src, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	fi, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return fmt.Errorf("%s is a directory", localPath)
+	}
+
+	dst, err := c.client.OpenFile(remotePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", remotePath, err)
+	}
+
+	_, err = copyWithProgress(ctx, dst, src, fi.Size(), progress)
+	if closeErr := dst.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write %s: %w", remotePath, closeErr)
+	}
+	if err == nil {
+		// Best effort, some servers refuse to change permissions
+		_ = c.client.Chmod(remotePath, fi.Mode().Perm())
+	}
+	return err
+}
+
+// Download copies a remote file to localPath, replacing it if it exists
+// A cancelled transfer leaves a partial file behind and returns ctx.Err()
+func (c *SFTPClient) Download(ctx context.Context, remotePath, localPath string, progress ProgressFunc) error {
+	src, err := c.client.Open(remotePath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	fi, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return fmt.Errorf("%s is a directory", remotePath)
+	}
+
+	dst, err := os.OpenFile(localPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	_, err = copyWithProgress(ctx, dst, src, fi.Size(), progress)
+	if closeErr := dst.Close(); err == nil && closeErr != nil {
+		err = closeErr
+	}
+	return err
+}
+
+// transferBufferSize is how much is copied between progress reports
+const transferBufferSize = 32 * 1024
+
+// copyWithProgress copies src to dst, reporting progress after every chunk
+// and stopping with ctx.Err() once ctx is done
+func copyWithProgress(ctx context.Context, dst io.Writer, src io.Reader, total int64, progress ProgressFunc) (int64, error) {
+	buf := make([]byte, transferBufferSize)
+	var done int64
+	if progress != nil {
+		progress(0, total)
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return done, err
+		}
+		n, readErr := src.Read(buf)
+		if n > 0 {
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return done, err
+			}
+			done += int64(n)
+			if progress != nil {
+				progress(done, total)
+			}
+		}
+		if readErr == io.EOF {
+			return done, nil
+		}
+		if readErr != nil {
+			return done, readErr
+		}
+	}
+}
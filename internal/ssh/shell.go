@@ -0,0 +1,86 @@
+package ssh
+
+import (
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Shell is an interactive PTY session on an existing connection
+// Closing it ends the remote shell but keeps the connection open
+type Shell struct {
+	session *ssh.Session
+	stdin   io.WriteCloser
+}
+
+// StartShell requests a PTY of the given size and starts a login shell
+// Remote output is written to stdout/stderr until the shell exits
+func (s *SSHClientWrapper) StartShell(term string, cols, rows int, stdout, stderr io.Writer) (*Shell, error) {
+	if !s.connected.Load() || s.client == nil {
+		return nil, fmt.Errorf("not connected to server")
+	}
+
+	session, err := s.client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	// Same locale handling as ExecuteCommand, ignored by servers that refuse it
+	_ = session.Setenv("LANG", "en_US.UTF-8")
+	_ = session.Setenv("LC_ALL", "en_US.UTF-8")
+
+	modes := ssh.TerminalModes{
+		ssh.ECHO:          1,
+		ssh.TTY_OP_ISPEED: 14400,
+		ssh.TTY_OP_OSPEED: 14400,
+	}
+	if err := session.RequestPty(term, rows, cols, modes); err != nil {
+		session.Close()
+		return nil, fmt.Errorf("failed to request pty: %w", err)
+	}
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return nil, fmt.Errorf("failed to open stdin: %w", err)
+	}
+	session.Stdout = stdout
+	session.Stderr = stderr
+
+	if err := session.Shell(); err != nil {
+		session.Close()
+		return nil, fmt.Errorf("failed to start shell: %w", err)
+	}
+
+	return &Shell{session: session, stdin: stdin}, nil
+}
+
+// Write sends keyboard input to the remote shell
+func (sh *Shell) Write(p []byte) (int, error) {
+	return sh.stdin.Write(p)
+}
+
+// Resize forwards a terminal size change as a window-change request
+func (sh *Shell) Resize(cols, rows int) error {
+	return sh.session.WindowChange(rows, cols)
+}
+
+// Wait blocks until the remote shell exits
+// A non-zero exit status of the shell itself is not treated as an error
+func (sh *Shell) Wait() error {
+	err := sh.session.Wait()
+	if _, ok := err.(*ssh.ExitError); ok {
+		return nil
+	}
+	if _, ok := err.(*ssh.ExitMissingError); ok {
+		// Happens when we close the session ourselves to detach
+		return nil
+	}
+	return err
+}
+
+// Close ends the shell session, the underlying connection stays open
+func (sh *Shell) Close() error {
+	return sh.session.Close()
+}
@@ -0,0 +1,127 @@
+package ssh
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// SOCKS5 protocol constants (RFC 1928)
+const (
+	socksVersion      = 0x05
+	socksNoAuth       = 0x00
+	socksNoAcceptable = 0xff
+	socksConnect      = 0x01
+
+	socksAddrIPv4   = 0x01
+	socksAddrDomain = 0x03
+	socksAddrIPv6   = 0x04
+
+	socksSucceeded          = 0x00
+	socksGeneralFailure     = 0x01
+	socksNetworkUnreachable = 0x03
+	socksHostUnreachable    = 0x04
+	socksConnectionRefused  = 0x05
+	socksCommandUnsupported = 0x07
+	socksAddrUnsupported    = 0x08
+)
+
+// socksHandshake negotiates a SOCKS5 CONNECT request and returns the requested host:port
+// Only the no-authentication method is offered, the proxy listens on localhost by default
+func socksHandshake(conn net.Conn) (string, error) {
+	// Greeting: version, number of methods, methods
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", fmt.Errorf("socks: failed to read greeting: %w", err)
+	}
+	if header[0] != socksVersion {
+		return "", fmt.Errorf("socks: unsupported version %d", header[0])
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return "", fmt.Errorf("socks: failed to read methods: %w", err)
+	}
+
+	method := byte(socksNoAcceptable)
+	for _, m := range methods {
+		if m == socksNoAuth {
+			method = socksNoAuth
+			break
+		}
+	}
+	if _, err := conn.Write([]byte{socksVersion, method}); err != nil {
+		return "", fmt.Errorf("socks: failed to reply to greeting: %w", err)
+	}
+	if method == socksNoAcceptable {
+		return "", fmt.Errorf("socks: client requires authentication")
+	}
+
+	// Request: version, command, reserved, address type, address, port
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return "", fmt.Errorf("socks: failed to read request: %w", err)
+	}
+	if request[1] != socksConnect {
+		_ = socksReply(conn, socksCommandUnsupported)
+		return "", fmt.Errorf("socks: unsupported command %d", request[1])
+	}
+
+	var host string
+	switch request[3] {
+	case socksAddrIPv4, socksAddrIPv6:
+		size := net.IPv4len
+		if request[3] == socksAddrIPv6 {
+			size = net.IPv6len
+		}
+		ip := make([]byte, size)
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return "", fmt.Errorf("socks: failed to read address: %w", err)
+		}
+		host = net.IP(ip).String()
+	case socksAddrDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return "", fmt.Errorf("socks: failed to read address: %w", err)
+		}
+		domain := make([]byte, length[0])
+		if _, err := io.ReadFull(conn, domain); err != nil {
+			return "", fmt.Errorf("socks: failed to read address: %w", err)
+		}
+		host = string(domain)
+	default:
+		_ = socksReply(conn, socksAddrUnsupported)
+		return "", fmt.Errorf("socks: unsupported address type %d", request[3])
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return "", fmt.Errorf("socks: failed to read port: %w", err)
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+// socksReply sends a reply to a CONNECT request
+// The bound address is reported as 0.0.0.0:0, clients don't need it for CONNECT
+func socksReply(conn net.Conn, code byte) error {
+	_, err := conn.Write([]byte{socksVersion, code, 0x00, socksAddrIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
+
+// socksReplyFor maps a dial failure to the closest SOCKS5 reply code
+func socksReplyFor(err error) byte {
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED), strings.Contains(err.Error(), "connection refused"):
+		return socksConnectionRefused
+	case errors.Is(err, syscall.ENETUNREACH):
+		return socksNetworkUnreachable
+	case strings.Contains(err.Error(), "no such host"), errors.Is(err, syscall.EHOSTUNREACH):
+		return socksHostUnreachable
+	default:
+		return socksGeneralFailure
+	}
+}
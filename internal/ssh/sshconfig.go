@@ -0,0 +1,639 @@
+package ssh
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxIncludeDepth matches OpenSSH's READCONF_MAX_DEPTH
+const maxIncludeDepth = 16
+
+// systemSSHConfigFile is read after the user's config, like OpenSSH does
+const systemSSHConfigFile = "/etc/ssh/ssh_config"
+
+// HostConfig holds the ssh_config settings resolved for a single host
+type HostConfig struct {
+	Host           string        // Name the lookup was done for
+	HostName       string        // Real host to connect to (defaults to Host)
+	User           string        // Empty if the config doesn't set one
+	Port           int           // 0 if the config doesn't set one
+	IdentityFiles  []string      // Expanded IdentityFile paths, in config order
+	IdentitiesOnly bool          // Only use the identity files, not every agent key
+	ProxyJump      string        // Raw ProxyJump value, empty or "none" means direct
+	ConnectTimeout time.Duration // 0 if the config doesn't set one
+}
+
+// configBlock is a Host or Match block, nested blocks come from Include
+type configBlock struct {
+	parent   *configBlock
+	host     []string         // Host patterns, nil for Match blocks
+	criteria []matchCriterion // Match criteria, nil for Host blocks
+}
+
+// matchCriterion is a single "[!]keyword [patterns]" part of a Match line
+type matchCriterion struct {
+	keyword  string
+	negate   bool
+	patterns string
+}
+
+// configDirective is a single "Keyword args" line and the block it lives in
+type configDirective struct {
+	keyword string   // Lowercased keyword
+	args    []string // Arguments with quotes removed
+	block   *configBlock
+}
+
+// matchContext carries what Host and Match blocks are evaluated against
+type matchContext struct {
+	originalHost string
+	hostName     string
+	user         string
+	localUser    string
+}
+
+// ResolveHost looks up host in ~/.ssh/config and /etc/ssh/ssh_config
+// user is the explicitly requested remote user (may be empty), it is used for Match user
+// Resolution is a single pass: Match final blocks always apply, as if this were
+// the final pass, and Match exec, canonical and tagged never do
+func ResolveHost(host string, user string) (*HostConfig, error) {
+	directives, err := loadSSHConfig()
+	if err != nil {
+		return nil, err
+	}
+	return resolveDirectives(directives, host, user), nil
+}
+
+// ConfigHosts resolves every concrete Host entry in ~/.ssh/config and its Includes
+// Wildcard and negated patterns are skipped, entries keep their config order
+func ConfigHosts() ([]*HostConfig, error) {
+	path, err := userSSHConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	userDirectives, err := parseSSHConfigFile(path, filepath.Dir(path), nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	directives, err := loadSSHConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []*HostConfig
+	seen := make(map[string]bool)
+	for _, d := range userDirectives {
+		if d.keyword != "host" {
+			continue
+		}
+		for _, pattern := range d.args {
+			if !isConcretePattern(pattern) || seen[pattern] {
+				continue
+			}
+			seen[pattern] = true
+			hosts = append(hosts, resolveDirectives(directives, pattern, ""))
+		}
+	}
+	return hosts, nil
+}
+
+// isConcretePattern reports whether a Host pattern names a single host
+func isConcretePattern(pattern string) bool {
+	return !strings.ContainsAny(pattern, "*?!")
+}
+
+// loadSSHConfig parses the user config followed by the system config
+func loadSSHConfig() ([]configDirective, error) {
+	var directives []configDirective
+
+	if path, err := userSSHConfigFile(); err == nil {
+		parsed, err := parseSSHConfigFile(path, filepath.Dir(path), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		directives = append(directives, parsed...)
+	}
+
+	parsed, err := parseSSHConfigFile(systemSSHConfigFile, filepath.Dir(systemSSHConfigFile), nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	directives = append(directives, parsed...)
+	return directives, nil
+}
+
+// userSSHConfigFile returns the path to ~/.ssh/config
+func userSSHConfigFile() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %v", err)
+	}
+	return filepath.Join(home, ".ssh", "config"), nil
+}
+
+// localUsername returns the name of the user running beacon
+func localUsername() string {
+	current, err := user.Current()
+	if err != nil {
+		return os.Getenv("USER")
+	}
+	// Windows reports DOMAIN\user
+	name := current.Username
+	if i := strings.LastIndex(name, `\`); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// resolveDirectives applies directives with first-match-wins semantics
+func resolveDirectives(directives []configDirective, host string, user string) *HostConfig {
+	resolved := &HostConfig{Host: host, User: user}
+	ctx := &matchContext{
+		originalHost: strings.ToLower(host),
+		hostName:     strings.ToLower(host),
+		user:         user,
+		localUser:    localUsername(),
+	}
+	if ctx.user == "" {
+		ctx.user = ctx.localUser
+	}
+
+	seen := make(map[string]bool)
+	for _, d := range directives {
+		if d.keyword == "host" || len(d.args) == 0 || !d.block.matches(ctx) {
+			continue
+		}
+
+		// IdentityFile accumulates, everything else keeps its first value
+		if d.keyword == "identityfile" {
+			resolved.IdentityFiles = append(resolved.IdentityFiles, d.args[0])
+			continue
+		}
+		if seen[d.keyword] {
+			continue
+		}
+		seen[d.keyword] = true
+
+		value := d.args[0]
+		switch d.keyword {
+		case "hostname":
+			resolved.HostName = value
+			ctx.hostName = strings.ToLower(expandTokens(value, resolved, ctx))
+		case "user":
+			if resolved.User == "" {
+				resolved.User = value
+				ctx.user = value
+			}
+		case "port":
+			if port, err := strconv.Atoi(value); err == nil {
+				resolved.Port = port
+			}
+		case "identitiesonly":
+			resolved.IdentitiesOnly = strings.EqualFold(value, "yes")
+		case "proxyjump":
+			resolved.ProxyJump = strings.Join(d.args, ",")
+		case "connecttimeout":
+			if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+				resolved.ConnectTimeout = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+
+	// Tokens may refer to the final values, so expand them last
+	if resolved.HostName == "" {
+		resolved.HostName = host
+	} else {
+		resolved.HostName = expandTokens(resolved.HostName, resolved, ctx)
+	}
+	for i, path := range resolved.IdentityFiles {
+		resolved.IdentityFiles[i] = expandTokens(path, resolved, ctx)
+		if expanded, err := expandPath(resolved.IdentityFiles[i]); err == nil {
+			resolved.IdentityFiles[i] = expanded
+		}
+	}
+	return resolved
+}
+
+// expandTokens expands the %-tokens OpenSSH supports in HostName and IdentityFile
+func expandTokens(value string, resolved *HostConfig, ctx *matchContext) string {
+	if !strings.Contains(value, "%") {
+		return value
+	}
+
+	home, _ := os.UserHomeDir()
+	port := resolved.Port
+	if port == 0 {
+		port = 22
+	}
+	hostName := resolved.HostName
+	if hostName == "" || strings.Contains(hostName, "%") {
+		hostName = resolved.Host
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '%' || i == len(value)-1 {
+			out.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case '%':
+			out.WriteByte('%')
+		case 'd':
+			out.WriteString(home)
+		case 'h':
+			out.WriteString(hostName)
+		case 'n':
+			out.WriteString(resolved.Host)
+		case 'p':
+			out.WriteString(strconv.Itoa(port))
+		case 'r':
+			out.WriteString(ctx.user)
+		case 'u':
+			out.WriteString(ctx.localUser)
+		default:
+			// Unknown token, keep it as written
+			out.WriteByte('%')
+			out.WriteByte(value[i])
+		}
+	}
+	return out.String()
+}
+
+// matches reports whether the block (and every enclosing block) applies
+func (b *configBlock) matches(ctx *matchContext) bool {
+	if b == nil {
+		return true
+	}
+	if !b.parent.matches(ctx) {
+		return false
+	}
+	if b.host != nil {
+		return matchHostPatterns(b.host, ctx.originalHost)
+	}
+
+	for _, c := range b.criteria {
+		var ok bool
+		switch c.keyword {
+		case "all", "final":
+			// beacon resolves in a single pass that doubles as the final one
+			ok = true
+		case "host":
+			ok = matchPatternList(c.patterns, ctx.hostName)
+		case "originalhost":
+			ok = matchPatternList(c.patterns, ctx.originalHost)
+		case "user":
+			ok = matchPatternList(c.patterns, ctx.user)
+		case "localuser":
+			ok = matchPatternList(c.patterns, ctx.localUser)
+		default:
+			// exec, canonical, tagged, ... aren't evaluated, their blocks never apply
+			ok = false
+		}
+		if ok == c.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// matchHostPatterns implements Host line matching: any negated match fails,
+// otherwise at least one positive pattern must match
+func matchHostPatterns(patterns []string, host string) bool {
+	matched := false
+	for _, pattern := range patterns {
+		negate := strings.HasPrefix(pattern, "!")
+		if negate {
+			pattern = pattern[1:]
+		}
+		if !wildcardMatch(strings.ToLower(pattern), host) {
+			continue
+		}
+		if negate {
+			return false
+		}
+		matched = true
+	}
+	return matched
+}
+
+// matchPatternList matches a comma separated Match pattern list
+func matchPatternList(list string, value string) bool {
+	return matchHostPatterns(strings.Split(list, ","), strings.ToLower(value))
+}
+
+// wildcardMatch matches s against a pattern with * and ? wildcards
+func wildcardMatch(pattern, s string) bool {
+	// Iterative matcher with single-star backtracking
+	p, i := 0, 0
+	star, mark := -1, 0
+	for i < len(s) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == s[i]):
+			p++
+			i++
+		case p < len(pattern) && pattern[p] == '*':
+			star = p
+			mark = i
+			p++
+		case star >= 0:
+			p = star + 1
+			mark++
+			i = mark
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// parseSSHConfigFile parses path into directives, following Include
+// Relative includes resolve against baseDir (~/.ssh or /etc/ssh) like OpenSSH
+// A missing file is not an error, it simply contributes nothing
+func parseSSHConfigFile(path string, baseDir string, parent *configBlock, depth int) ([]configDirective, error) {
+	if depth > maxIncludeDepth {
+		return nil, fmt.Errorf("ssh config: Include nested too deeply at %s", path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open ssh config: %v", err)
+	}
+	defer file.Close()
+
+	var directives []configDirective
+	block := parent
+	lineNum := 0
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineNum++
+		keyword, args, err := splitConfigLine(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("ssh config %s:%d: %v", path, lineNum, err)
+		}
+		if keyword == "" {
+			continue
+		}
+
+		switch keyword {
+		case "host":
+			// Without patterns the block would apply to every host, ssh rejects it too
+			if len(args) == 0 {
+				return nil, fmt.Errorf("ssh config %s:%d: Host requires at least one pattern", path, lineNum)
+			}
+			block = &configBlock{parent: parent, host: args}
+			// Recorded so ConfigHosts can list the entries, resolution skips it
+			directives = append(directives, configDirective{keyword: keyword, args: args, block: block})
+		case "match":
+			criteria, err := parseMatchCriteria(args)
+			if err != nil {
+				return nil, fmt.Errorf("ssh config %s:%d: %v", path, lineNum, err)
+			}
+			block = &configBlock{parent: parent, criteria: criteria}
+		case "include":
+			for _, pattern := range args {
+				included, err := includeSSHConfig(baseDir, pattern, block, depth)
+				if err != nil {
+					return nil, err
+				}
+				directives = append(directives, included...)
+			}
+		case "port":
+			if len(args) == 0 {
+				return nil, fmt.Errorf("ssh config %s:%d: Port requires an argument", path, lineNum)
+			}
+			if port, err := strconv.Atoi(args[0]); err != nil || port < 1 || port > 65535 {
+				return nil, fmt.Errorf("ssh config %s:%d: Port must be between 1 and 65535, got %q", path, lineNum, args[0])
+			}
+			directives = append(directives, configDirective{keyword: keyword, args: args, block: block})
+		default:
+			directives = append(directives, configDirective{keyword: keyword, args: args, block: block})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ssh config: %v", err)
+	}
+	return directives, nil
+}
+
+// includeSSHConfig expands an Include pattern and parses every matching file
+func includeSSHConfig(baseDir string, pattern string, block *configBlock, depth int) ([]configDirective, error) {
+	pattern, err := expandPath(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(baseDir, pattern)
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("ssh config: bad Include pattern %q: %v", pattern, err)
+	}
+
+	var directives []configDirective
+	for _, match := range matches {
+		// Included files start outside any Host block but inherit the enclosing one
+		included, err := parseSSHConfigFile(match, baseDir, block, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		directives = append(directives, included...)
+	}
+	return directives, nil
+}
+
+// parseMatchCriteria parses the arguments of a Match line
+func parseMatchCriteria(args []string) ([]matchCriterion, error) {
+	// Without criteria the block would apply to every host, ssh rejects it too
+	if len(args) == 0 {
+		return nil, fmt.Errorf("Match requires at least one criterion")
+	}
+	var criteria []matchCriterion
+	for i := 0; i < len(args); i++ {
+		c := matchCriterion{keyword: strings.ToLower(args[i])}
+		if strings.HasPrefix(c.keyword, "!") {
+			c.negate = true
+			c.keyword = c.keyword[1:]
+		}
+
+		switch c.keyword {
+		case "all", "canonical", "final":
+			// These take no argument
+		default:
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("Match %s requires an argument", c.keyword)
+			}
+			i++
+			c.patterns = args[i]
+		}
+		criteria = append(criteria, c)
+	}
+	return criteria, nil
+}
+
+// splitConfigLine splits "Keyword args", "Keyword=args" and quoted arguments
+func splitConfigLine(line string) (string, []string, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", nil, nil
+	}
+
+	// The keyword ends at the first whitespace or '='
+	end := strings.IndexAny(line, " \t=")
+	if end < 0 {
+		return strings.ToLower(line), nil, nil
+	}
+	keyword := strings.ToLower(line[:end])
+	rest := strings.TrimLeft(line[end:], " \t")
+	rest = strings.TrimPrefix(rest, "=")
+
+	var args []string
+	var current strings.Builder
+	inQuotes, hasArg := false, false
+	for _, r := range rest {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			hasArg = true
+		case (r == ' ' || r == '\t') && !inQuotes:
+			if hasArg {
+				args = append(args, current.String())
+				current.Reset()
+				hasArg = false
+			}
+		case r == '#' && !inQuotes && !hasArg:
+			// Trailing comment
+			return keyword, args, nil
+		default:
+			current.WriteRune(r)
+			hasArg = true
+		}
+	}
+	if inQuotes {
+		return "", nil, fmt.Errorf("unterminated quote")
+	}
+	if hasArg {
+		args = append(args, current.String())
+	}
+	return keyword, args, nil
+}
+
+// JumpHost is one hop on the way to the target, dialed through the previous hop
+type JumpHost struct {
+	Host           string // Hostname or ssh config alias
+	Port           int    // 0 resolves from ssh config or 22
+	User           string // Empty resolves from ssh config or the local user
+	KeyPath        string // Optional key for this hop
+	KnownHostsFile string // Optional known_hosts override for this hop
+	Password       string // Optional stored password for this hop
+}
+
+// String returns the hop as [user@]host[:port]
+func (j JumpHost) String() string {
+	spec := j.Host
+	if j.Port != 0 {
+		spec = net.JoinHostPort(j.Host, strconv.Itoa(j.Port))
+	}
+	if j.User != "" {
+		spec = j.User + "@" + spec
+	}
+	return spec
+}
+
+// parseProxyJump parses "[user@]host[:port],..." and ssh:// URIs
+func parseProxyJump(value string) ([]JumpHost, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || strings.EqualFold(value, "none") {
+		return nil, nil
+	}
+
+	var hops []JumpHost
+	for _, part := range strings.Split(value, ",") {
+		hop, err := ParseJumpSpec(part)
+		if err != nil {
+			return nil, err
+		}
+		hops = append(hops, hop)
+	}
+	return hops, nil
+}
+
+// maxJumpHops bounds a jump chain grown by following ProxyJump
+const maxJumpHops = 16
+
+// followProxyJump prepends the ProxyJump of the first hop from ssh config,
+// again and again, like ssh does: the first hop is the only one dialed
+// directly, later hops are reached through the chain so theirs don't apply
+func followProxyJump(directives []configDirective, hops []JumpHost) ([]JumpHost, error) {
+	seen := make(map[string]bool)
+	for len(hops) > 0 {
+		first := hops[0]
+		key := strings.ToLower(first.Host)
+		if seen[key] {
+			return nil, fmt.Errorf("ProxyJump of jump host %s loops back to it", first.Host)
+		}
+		seen[key] = true
+
+		resolved := resolveDirectives(directives, first.Host, first.User)
+		before, err := parseProxyJump(resolved.ProxyJump)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ProxyJump for jump host %s: %v", first.Host, err)
+		}
+		if len(before) == 0 {
+			return hops, nil
+		}
+		if len(before)+len(hops) > maxJumpHops {
+			return nil, fmt.Errorf("jump chain through %s is longer than %d hops", first.Host, maxJumpHops)
+		}
+		for i := range before {
+			before[i].KnownHostsFile = first.KnownHostsFile
+		}
+		hops = append(before, hops...)
+	}
+	return hops, nil
+}
+
+// ParseJumpSpec parses a single "[user@]host[:port]" or ssh://[user@]host[:port]
+func ParseJumpSpec(spec string) (JumpHost, error) {
+	spec = strings.TrimPrefix(strings.TrimSpace(spec), "ssh://")
+	if spec == "" {
+		return JumpHost{}, fmt.Errorf("empty jump host")
+	}
+
+	var hop JumpHost
+	if i := strings.LastIndex(spec, "@"); i >= 0 {
+		hop.User = spec[:i]
+		spec = spec[i+1:]
+	}
+
+	hop.Host = spec
+	if host, portStr, err := net.SplitHostPort(spec); err == nil {
+		port, err := strconv.Atoi(portStr)
+		if err != nil || port <= 0 || port > 65535 {
+			return JumpHost{}, fmt.Errorf("invalid port in jump host %q", spec)
+		}
+		hop.Host = host
+		hop.Port = port
+	}
+	if hop.Host == "" {
+		return JumpHost{}, fmt.Errorf("missing host in jump host %q", spec)
+	}
+	return hop, nil
+}
@@ -0,0 +1,439 @@
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeSSHConfig writes files under dir and parses dir/config the way
+// loadSSHConfig parses ~/.ssh/config
+func writeSSHConfig(t *testing.T, dir string, files map[string]string) []configDirective {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	directives, err := parseSSHConfigFile(filepath.Join(dir, "config"), dir, nil, 0)
+	if err != nil {
+		t.Fatalf("parseSSHConfigFile: %v", err)
+	}
+	return directives
+}
+
+func TestResolveFirstMatchWins(t *testing.T) {
+	directives := writeSSHConfig(t, t.TempDir(), map[string]string{"config": `
+Host web
+  HostName web.example.com
+  Port 2222
+  IdentityFile /keys/web
+
+Host *
+  Port 22
+  User fallback
+  IdentityFile /keys/default
+  ConnectTimeout 5
+`})
+
+	tests := []struct {
+		name string
+		host string
+		user string
+		want HostConfig
+	}{
+		{
+			name: "specific block before wildcard",
+			host: "web",
+			want: HostConfig{Host: "web", HostName: "web.example.com", User: "fallback", Port: 2222,
+				IdentityFiles: []string{"/keys/web", "/keys/default"}, ConnectTimeout: 5 * time.Second},
+		},
+		{
+			name: "only wildcard",
+			host: "other",
+			want: HostConfig{Host: "other", HostName: "other", User: "fallback", Port: 22,
+				IdentityFiles: []string{"/keys/default"}, ConnectTimeout: 5 * time.Second},
+		},
+		{
+			name: "explicit user wins over config",
+			host: "other",
+			user: "root",
+			want: HostConfig{Host: "other", HostName: "other", User: "root", Port: 22,
+				IdentityFiles: []string{"/keys/default"}, ConnectTimeout: 5 * time.Second},
+		},
+		{
+			name: "host patterns are case-insensitive",
+			host: "WEB",
+			want: HostConfig{Host: "WEB", HostName: "web.example.com", User: "fallback", Port: 2222,
+				IdentityFiles: []string{"/keys/web", "/keys/default"}, ConnectTimeout: 5 * time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resolveDirectives(directives, tt.host, tt.user)
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveInclude(t *testing.T) {
+	dir := t.TempDir()
+	directives := writeSSHConfig(t, dir, map[string]string{
+		"config": `
+Include conf.d/*.conf
+Include ` + filepath.Join(dir, "absolute.conf") + `
+Include missing/*.conf
+
+Host scoped
+  Include scoped.conf
+
+Host *
+  Port 22
+`,
+		"conf.d/10-a.conf":   "Host a\n  HostName a.example.com\n",
+		"conf.d/20-b.conf":   "Host b\n  HostName b.example.com\nHost a\n  HostName shadowed.example.com\n",
+		"conf.d/ignored.txt": "Host a\n  Port 1\n",
+		"absolute.conf":      "Host abs\n  Port 2200\n",
+		"scoped.conf":        "Port 2022\n",
+	})
+
+	tests := []struct {
+		host     string
+		hostName string
+		port     int
+	}{
+		// Files matched by a glob are read in order, the first HostName wins
+		{host: "a", hostName: "a.example.com", port: 22},
+		{host: "b", hostName: "b.example.com", port: 22},
+		{host: "abs", hostName: "abs", port: 2200},
+		// An Include inside a Host block only applies to that host
+		{host: "scoped", hostName: "scoped", port: 2022},
+		{host: "other", hostName: "other", port: 22},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			got := resolveDirectives(directives, tt.host, "")
+			if got.HostName != tt.hostName || got.Port != tt.port {
+				t.Errorf("got HostName %q Port %d, want %q %d", got.HostName, got.Port, tt.hostName, tt.port)
+			}
+		})
+	}
+}
+
+func TestIncludeDepthLimit(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config")
+	if err := os.WriteFile(path, []byte("Include config\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseSSHConfigFile(path, dir, nil, 0); err == nil {
+		t.Fatal("expected an error for an Include loop")
+	}
+}
+
+func TestResolveMatch(t *testing.T) {
+	local := localUsername()
+	directives := writeSSHConfig(t, t.TempDir(), map[string]string{"config": `
+Host web
+  HostName web.internal
+
+Host deployer
+  User deploy
+
+Match host *.internal
+  Port 2200
+Match originalhost web
+  IdentityFile /keys/orig
+Match user deploy
+  IdentityFile /keys/deploy
+Match !user deploy host db
+  IdentityFile /keys/not-deploy
+Match localuser ` + local + ` host local
+  IdentityFile /keys/local
+Match exec "true"
+  IdentityFile /keys/exec
+Match final host fin
+  IdentityFile /keys/final
+Match all
+  IdentityFile /keys/all
+`})
+
+	tests := []struct {
+		name string
+		host string
+		user string
+		port int
+		keys []string
+	}{
+		{name: "host matches HostName, originalhost the alias", host: "web", port: 2200,
+			keys: []string{"/keys/orig", "/keys/all"}},
+		{name: "user set in config", host: "deployer",
+			keys: []string{"/keys/deploy", "/keys/all"}},
+		{name: "explicit user", host: "db", user: "deploy",
+			keys: []string{"/keys/deploy", "/keys/all"}},
+		{name: "negated user", host: "db", user: "admin",
+			keys: []string{"/keys/not-deploy", "/keys/all"}},
+		{name: "localuser", host: "local",
+			keys: []string{"/keys/local", "/keys/all"}},
+		{name: "final always applies", host: "fin",
+			keys: []string{"/keys/final", "/keys/all"}},
+		{name: "exec never applies", host: "plain",
+			keys: []string{"/keys/all"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resolveDirectives(directives, tt.host, tt.user)
+			if got.Port != tt.port {
+				t.Errorf("Port = %d, want %d", got.Port, tt.port)
+			}
+			if !reflect.DeepEqual(got.IdentityFiles, tt.keys) {
+				t.Errorf("IdentityFiles = %q, want %q", got.IdentityFiles, tt.keys)
+			}
+		})
+	}
+}
+
+func TestParseMatchCriteriaErrors(t *testing.T) {
+	for _, args := range [][]string{nil, {"host"}, {"all", "user"}, {"!exec"}} {
+		if _, err := parseMatchCriteria(args); err == nil {
+			t.Errorf("parseMatchCriteria(%q) succeeded, want an error", args)
+		}
+	}
+}
+
+func TestParseSSHConfigErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		err    string // Expected in the error, "" when the config is valid
+	}{
+		{name: "Host without patterns", config: "Host web\n  User a\nHost\n  User b\n", err: "config:3: Host requires"},
+		{name: "Host with only a comment", config: "Host # everything\n", err: "config:1: Host requires"},
+		{name: "Match without criteria", config: "\nMatch\n  User b\n", err: "config:2: Match requires"},
+		{name: "port 0", config: "Port 0\n", err: "config:1: Port must be between 1 and 65535"},
+		{name: "port 65536", config: "Host web\n  Port 65536\n", err: "config:2: Port must be between 1 and 65535"},
+		{name: "negative port", config: "Port -22\n", err: "config:1: Port must be between 1 and 65535"},
+		{name: "port name", config: "Port ssh\n", err: "config:1: Port must be between 1 and 65535"},
+		{name: "port missing", config: "Port\n", err: "config:1: Port requires an argument"},
+		{name: "lowest port", config: "Port 1\n"},
+		{name: "highest port", config: "Host web\n  Port=65535\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "config")
+			if err := os.WriteFile(path, []byte(tt.config), 0600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := parseSSHConfigFile(path, dir, nil, 0)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("parseSSHConfigFile: %v", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("parseSSHConfigFile: %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestResolveTokens(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	local := localUsername()
+	directives := writeSSHConfig(t, t.TempDir(), map[string]string{"config": `
+Host bastion
+  HostName %h.example.com
+  Port 2222
+  User ops
+  IdentityFile %d/.ssh/%r@%h
+  IdentityFile ~/.ssh/%n-%p
+  IdentityFile /keys/%u
+  IdentityFile /keys/100%%-%z
+
+Host plain
+  IdentityFile /keys/%h-%p-%r
+`})
+
+	tests := []struct {
+		host     string
+		user     string
+		hostName string
+		keys     []string
+	}{
+		{
+			host:     "bastion",
+			hostName: "bastion.example.com",
+			keys: []string{
+				filepath.Join(home, ".ssh", "ops@bastion.example.com"),
+				filepath.Join(home, ".ssh", "bastion-2222"),
+				"/keys/" + local,
+				// %% is a literal percent, unknown tokens are kept
+				"/keys/100%-%z",
+			},
+		},
+		{
+			host:     "plain",
+			user:     "me",
+			hostName: "plain",
+			keys:     []string{"/keys/plain-22-me"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			got := resolveDirectives(directives, tt.host, tt.user)
+			if got.HostName != tt.hostName {
+				t.Errorf("HostName = %q, want %q", got.HostName, tt.hostName)
+			}
+			if !reflect.DeepEqual(got.IdentityFiles, tt.keys) {
+				t.Errorf("IdentityFiles = %q, want %q", got.IdentityFiles, tt.keys)
+			}
+		})
+	}
+}
+
+func TestMatchHostPatterns(t *testing.T) {
+	tests := []struct {
+		patterns string
+		host     string
+		want     bool
+	}{
+		{"*", "anything", true},
+		{"web?", "web1", true},
+		{"web?", "web10", false},
+		{"*.example.com", "db.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com !bad.example.com", "db.example.com", true},
+		// A negated match fails the line even when a positive pattern matches
+		{"*.example.com !bad.example.com", "bad.example.com", false},
+		{"!bad.example.com", "good.example.com", false},
+		{"!*.internal *", "db.internal", false},
+		{"!*.internal *", "db.example.com", true},
+		{"a*b*c", "axxbyyc", true},
+		{"a*b*c", "axxbyy", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.patterns+"/"+tt.host, func(t *testing.T) {
+			if got := matchHostPatterns(strings.Fields(tt.patterns), tt.host); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitConfigLine(t *testing.T) {
+	tests := []struct {
+		line    string
+		keyword string
+		args    []string
+		wantErr bool
+	}{
+		{line: "  # comment"},
+		{line: ""},
+		{line: "HostName example.com", keyword: "hostname", args: []string{"example.com"}},
+		{line: "Port=2222", keyword: "port", args: []string{"2222"}},
+		{line: "Port = 2222", keyword: "port", args: []string{"2222"}},
+		{line: `IdentityFile "~/my keys/id"`, keyword: "identityfile", args: []string{"~/my keys/id"}},
+		{line: "Host a b # trailing", keyword: "host", args: []string{"a", "b"}},
+		{line: `Match exec "test -f /x"`, keyword: "match", args: []string{"exec", "test -f /x"}},
+		{line: `User "unterminated`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			keyword, args, err := splitConfigLine(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if keyword != tt.keyword || !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("got %q %q, want %q %q", keyword, args, tt.keyword, tt.args)
+			}
+		})
+	}
+}
+
+func TestParseJumpSpec(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    JumpHost
+		wantErr bool
+	}{
+		{spec: "bastion", want: JumpHost{Host: "bastion"}},
+		{spec: "ops@bastion:2222", want: JumpHost{Host: "bastion", User: "ops", Port: 2222}},
+		{spec: "ssh://ops@bastion", want: JumpHost{Host: "bastion", User: "ops"}},
+		{spec: "[2001:db8::1]:22", want: JumpHost{Host: "2001:db8::1", Port: 22}},
+		{spec: "user@domain@bastion", want: JumpHost{Host: "bastion", User: "user@domain"}},
+		{spec: "bastion:99999", wantErr: true},
+		{spec: "ops@", wantErr: true},
+		{spec: " ", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseJumpSpec(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFollowProxyJump(t *testing.T) {
+	directives := writeSSHConfig(t, t.TempDir(), map[string]string{"config": `
+Host inner
+  ProxyJump middle
+Host middle
+  ProxyJump outer:2222
+Host outer
+  ProxyJump none
+Host second
+  ProxyJump ignored
+Host loop-a
+  ProxyJump loop-b
+Host loop-b
+  ProxyJump loop-a
+Host bad
+  ProxyJump host:notaport
+`})
+
+	tests := []struct {
+		name    string
+		hops    []JumpHost
+		want    []string
+		wantErr bool
+	}{
+		{name: "direct", hops: nil, want: nil},
+		{name: "no ProxyJump", hops: []JumpHost{{Host: "outer"}}, want: []string{"outer"}},
+		{name: "first hop's chain is followed", hops: []JumpHost{{Host: "inner"}},
+			want: []string{"outer:2222", "middle", "inner"}},
+		// Later hops are dialed through the chain, their ProxyJump doesn't apply
+		{name: "later hops", hops: []JumpHost{{Host: "outer"}, {Host: "second"}},
+			want: []string{"outer", "second"}},
+		{name: "loop", hops: []JumpHost{{Host: "loop-a"}}, wantErr: true},
+		{name: "invalid", hops: []JumpHost{{Host: "bad"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hops, err := followProxyJump(directives, tt.hops)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, hop := range hops {
+				got = append(got, hop.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}